@@ -0,0 +1,163 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrCircuitOpen ...
+// Returned for requests to a host whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// The outcome of an allowed request
+type outcome int
+
+const (
+	outcomeSucceeded outcome = iota
+	outcomeFailed
+	// The caller abandoned the request, which says nothing about the health of the host
+	outcomeAbandoned
+)
+
+// A set of circuit breakers keyed by host
+type breakers struct {
+	config *BreakerConfig
+
+	mu sync.Mutex
+	// The circuits of hosts with recent failures. Hosts without a circuit are closed with no failures.
+	circuits map[string]*circuit
+	// The last generation assigned to a circuit. Generations are unique across hosts so that
+	// requests allowed by a dropped circuit never match a later circuit for the same host.
+	generation uint64
+}
+
+// The circuit breaker of a single host
+type circuit struct {
+	state circuitState
+	// Identifies the current state of the circuit, as outcomes only apply to the state which allowed the request
+	generation uint64
+	failures   int
+	openedAt   time.Time
+	// The number of probe requests in flight while half open
+	probes int
+}
+
+func newBreakers(config *BreakerConfig) *breakers {
+	return &breakers{config: config, circuits: make(map[string]*circuit)}
+}
+
+// Returns the generation of the host's circuit if a request to the given host is allowed,
+// or an error wrapping ErrCircuitOpen otherwise. Every allowed request must be followed by
+// a call to done with the returned generation.
+func (b *breakers) allow(host string, now time.Time) (uint64, error) {
+	if !b.config.Enabled() {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[host]
+	if !ok {
+		return 0, nil
+	}
+	if c.state == circuitOpen && now.Sub(c.openedAt) >= b.config.OpenTimeout {
+		b.transition(host, c, circuitHalfOpen)
+	}
+	switch c.state {
+	case circuitOpen:
+		return 0, fmt.Errorf("%w for host (%s)", ErrCircuitOpen, host)
+	case circuitHalfOpen:
+		if c.probes >= b.config.HalfOpenRequests {
+			return 0, fmt.Errorf("%w for host (%s)", ErrCircuitOpen, host)
+		}
+		c.probes++
+	}
+	return c.generation, nil
+}
+
+// Records the outcome of a request to the given host which was allowed in the given generation.
+// Outcomes of requests allowed before the circuit last changed state are ignored (e.g. a slow
+// success must not close a circuit which opened in the meantime). Abandoned requests only
+// release their probe (if any), leaving the state of the circuit unchanged.
+func (b *breakers) done(host string, generation uint64, result outcome, now time.Time) {
+	if !b.config.Enabled() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[host]
+	if !ok {
+		if generation != 0 || result != outcomeFailed {
+			return
+		}
+		c = &circuit{}
+		b.circuits[host] = c
+	} else if c.generation != generation {
+		return
+	}
+
+	if c.state == circuitHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+	switch result {
+	case outcomeAbandoned:
+		return
+	case outcomeSucceeded:
+		if c.state != circuitClosed {
+			b.transition(host, c, circuitClosed)
+		}
+		// Closed circuits without failures are dropped so that only hosts with recent failures are held
+		delete(b.circuits, host)
+		return
+	}
+
+	c.failures++
+	if c.state == circuitHalfOpen || (c.state == circuitClosed && c.failures >= b.config.FailureThreshold) {
+		c.openedAt = now
+		b.transition(host, c, circuitOpen)
+	}
+}
+
+// ========== Private Helpers ==========
+
+func (b *breakers) transition(host string, c *circuit, state circuitState) {
+	event := log.Info()
+	if state == circuitOpen {
+		event = log.Warn()
+	}
+	event.Str("host", host).
+		Stringer("from", c.state).
+		Stringer("to", state).
+		Int("failures", c.failures).
+		Msg("HTTP client circuit breaker state changed")
+	c.state = state
+	b.generation++
+	c.generation = b.generation
+	if state != circuitHalfOpen {
+		c.probes = 0
+	}
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// The header used to propagate request IDs (see the server's RequestIDHandler)
+const requestIDHeader = "Request-Id"
+
+// The limit on response bytes drained from a retried attempt in order to reuse its connection
+const maxDrainBytes = 64 << 10
+
+// NewHTTPClient ...
+// Creates an HTTP client with the given configuration. Requests made with the client:
+//
+//   - Are bounded by the configured timeouts
+//   - Are retried with jittered exponential backoff after transport errors or retryable
+//     statuses, honoring Retry-After. Only idempotent requests (or requests with an
+//     Idempotency-Key header) whose bodies can be replayed are retried.
+//   - Are rejected with ErrCircuitOpen while the circuit breaker of their host is open
+//   - Propagate the request ID and trace context of their context, so that the context
+//     of an inbound server request should be used (see http.Request.WithContext)
+//   - Are logged through the context logger (or the global logger) in the same shape as
+//     the server access log
+func NewHTTPClient(config *HTTPClientConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: config.DialTimeout, KeepAlive: 30 * time.Second}
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+	}
+	return &http.Client{Transport: NewTransport(config, base)}
+}
+
+// Transport ...
+// An http.RoundTripper which adds timeouts, retries, circuit breaking, propagation and
+// logging to a base round tripper (see NewHTTPClient)
+type Transport struct {
+	config     *HTTPClientConfig
+	base       http.RoundTripper
+	breakers   *breakers
+	propagator propagation.TextMapPropagator
+}
+
+// NewTransport ...
+// Creates a Transport which sends requests with the given base round tripper
+func NewTransport(config *HTTPClientConfig, base http.RoundTripper) *Transport {
+	return &Transport{
+		config:     config,
+		base:       base,
+		breakers:   newBreakers(config.BreakerConfig),
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+}
+
+// RoundTrip ...
+// Implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	// Round trippers must not modify the given request
+	req = req.Clone(ctx)
+	if req.Header.Get(requestIDHeader) == "" {
+		if reqID, ok := hlog.IDFromCtx(ctx); ok {
+			req.Header.Set(requestIDHeader, reqID.String())
+		}
+	}
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	maxAttempts := 1
+	if t.config.RetryConfig.Enabled() && retryable(req) {
+		maxAttempts = t.config.RetryConfig.MaxAttempts
+	}
+	host := req.URL.Host
+	for attempt := 1; ; attempt++ {
+		resp, err := t.attempt(req, host, attempt)
+		if attempt >= maxAttempts {
+			return resp, err
+		}
+		wait, retry := t.retryWait(ctx, resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.CopyN(io.Discard, resp.Body, maxDrainBytes) // nolint:errcheck
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// ========== Private Helpers ==========
+
+// Sends a single attempt of the given request and logs its outcome
+func (t *Transport) attempt(req *http.Request, host string, attempt int) (*http.Response, error) {
+	ctx := req.Context()
+	logger := requestLogger(ctx)
+	start := time.Now()
+	generation, err := t.breakers.allow(host, start)
+	if err != nil {
+		logger.Warn().Err(err).Str("method", req.Method).Stringer("url", req.URL).Int("attempt", attempt).Msg("Rejected HTTP client request")
+		return nil, err
+	}
+
+	attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+	if t.config.Timeout > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, t.config.Timeout)
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(attemptCtx))
+	duration := time.Since(start)
+	t.breakers.done(host, generation, attemptOutcome(ctx, resp, err), time.Now())
+
+	if err != nil {
+		cancel()
+		logger.Warn().
+			Err(err).
+			Str("method", req.Method).
+			Stringer("url", req.URL).
+			Dur("duration", duration).
+			Int("attempt", attempt).
+			Msg("Failed HTTP client request")
+		return nil, err
+	}
+
+	logger.Info().
+		Str("method", req.Method).
+		Str("proto", resp.Proto).
+		Stringer("url", req.URL).
+		Int("status", resp.StatusCode).
+		Int64("size", resp.ContentLength).
+		Dur("duration", duration).
+		Int("attempt", attempt).
+		Msg("Finished HTTP client request")
+	// The attempt deadline must cover reading the body, so it is released once the body is closed
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// Returns the time to wait before retrying the given outcome of an attempt, or false if
+// the attempt should not be retried
+func (t *Transport) retryWait(ctx context.Context, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	retryConfig := t.config.RetryConfig
+	if err != nil {
+		// Do not retry if the caller gave up or the host's circuit is open
+		if ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+			return 0, false
+		}
+		return backoff(retryConfig, attempt), true
+	}
+	if !containsStatus(retryConfig.Statuses, resp.StatusCode) {
+		return 0, false
+	}
+
+	wait := backoff(retryConfig, attempt)
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		if retryAfter > retryConfig.MaxRetryAfter {
+			return 0, false
+		}
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+	}
+	return wait, true
+}
+
+// Returns the outcome of an attempt for the circuit breaker of its host. Requests
+// abandoned by the caller neither count against the host nor in its favor.
+func attemptOutcome(ctx context.Context, resp *http.Response, err error) outcome {
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return outcomeAbandoned
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		return outcomeFailed
+	default:
+		return outcomeSucceeded
+	}
+}
+
+// A response body which releases the attempt context once closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// Returns the logger of the given context, or the global logger if there is none
+func requestLogger(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
+
+// Returns true if the given request may be sent more than once
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// Returns a random backoff of up to the exponential backoff for the given attempt ("full jitter")
+// See https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+func backoff(retryConfig *RetryConfig, attempt int) time.Duration {
+	ceiling := float64(retryConfig.InitialBackoff) * math.Pow(retryConfig.Multiplier, float64(attempt-1))
+	ceiling = math.Min(ceiling, float64(retryConfig.MaxBackoff))
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// Parses a Retry-After header value, which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
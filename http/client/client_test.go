@@ -0,0 +1,320 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/client"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Creates a client configuration with fast retries and no circuit breaker
+func makeConfig() *client.HTTPClientConfig {
+	return &client.HTTPClientConfig{
+		Timeout: time.Second,
+		RetryConfig: &client.RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     10 * time.Millisecond,
+			Multiplier:     2,
+			Statuses:       []int{429, 502, 503, 504},
+			MaxRetryAfter:  2 * time.Second,
+		},
+		BreakerConfig: &client.BreakerConfig{},
+	}
+}
+
+// Creates a server which responds with the given statuses in order (and then 200) and counts requests
+func makeStatusServer(requests *int32, headers http.Header, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(requests, 1))
+		if n <= len(statuses) {
+			for k, v := range headers {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body) // nolint:errcheck
+	}))
+}
+
+func TestNewHTTPClientConfig(t *testing.T) {
+	assert := assert.New(t)
+	config, err := client.NewHTTPClientConfig(envconfig.MapLookuper(map[string]string{"RETRY_MAX_ATTEMPTS": "5", "BREAKER_FAILURE_THRESHOLD": "0"}))
+	if assert.NoError(err) {
+		assert.Equal(10*time.Second, config.Timeout)
+		assert.Equal(5, config.RetryConfig.MaxAttempts)
+		assert.Equal([]int{429, 502, 503, 504}, config.RetryConfig.Statuses)
+		assert.False(config.BreakerConfig.Enabled())
+	}
+
+	_, err = client.NewHTTPClientConfig(envconfig.MapLookuper(map[string]string{"TIMEOUT": "soon"}))
+	assert.Error(err)
+}
+
+func TestRetry(t *testing.T) {
+	testCases := map[string]struct {
+		method           string
+		headers          map[string]string
+		statuses         []int
+		expectedStatus   int
+		expectedRequests int32
+	}{
+		"success":                  {"GET", nil, nil, 200, 1},
+		"retried":                  {"GET", nil, []int{503, 502}, 200, 3},
+		"attempts exhausted":       {"GET", nil, []int{503, 503, 503}, 503, 3},
+		"not retryable status":     {"GET", nil, []int{500}, 500, 1},
+		"not idempotent":           {"POST", nil, []int{503}, 503, 1},
+		"idempotency key":          {"POST", map[string]string{"Idempotency-Key": "abc"}, []int{503}, 200, 2},
+		"idempotent with body":     {"PUT", nil, []int{503}, 200, 2},
+		"client error not retried": {"PUT", nil, []int{404}, 404, 1},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			var requests int32
+			server := makeStatusServer(&requests, nil, tc.statuses...)
+			defer server.Close()
+
+			req, _ := http.NewRequest(tc.method, server.URL, strings.NewReader("payload"))
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := client.NewHTTPClient(makeConfig()).Do(req)
+			if assert.NoError(err) {
+				defer resp.Body.Close()
+				assert.Equal(tc.expectedStatus, resp.StatusCode)
+				if resp.StatusCode == 200 {
+					// Retried bodies are replayed
+					body, _ := io.ReadAll(resp.Body)
+					assert.Equal("payload", string(body))
+				}
+			}
+			assert.Equal(tc.expectedRequests, atomic.LoadInt32(&requests))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+	var requests int32
+	server := makeStatusServer(&requests, http.Header{"Retry-After": {"1"}}, 429)
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := client.NewHTTPClient(makeConfig()).Get(server.URL)
+	if assert.NoError(err) {
+		resp.Body.Close()
+		assert.Equal(200, resp.StatusCode)
+	}
+	assert.Equal(int32(2), atomic.LoadInt32(&requests))
+	assert.GreaterOrEqual(time.Since(start), time.Second)
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	assert := assert.New(t)
+	var requests int32
+	server := makeStatusServer(&requests, http.Header{"Retry-After": {"120"}}, 503)
+	defer server.Close()
+
+	resp, err := client.NewHTTPClient(makeConfig()).Get(server.URL)
+	if assert.NoError(err) {
+		resp.Body.Close()
+		assert.Equal(503, resp.StatusCode)
+		assert.Equal("120", resp.Header.Get("Retry-After"))
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestTimeout(t *testing.T) {
+	assert := assert.New(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	config := makeConfig()
+	config.Timeout = 20 * time.Millisecond
+	_, err := client.NewHTTPClient(config).Get(server.URL)
+	assert.Error(err)
+	// Timed out attempts are retried
+	assert.Equal(int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+	var requests int32
+	server := makeStatusServer(&requests, nil, 500, 500)
+	defer server.Close()
+
+	config := makeConfig()
+	config.RetryConfig.MaxAttempts = 1
+	config.BreakerConfig = &client.BreakerConfig{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond, HalfOpenRequests: 1}
+	httpClient := client.NewHTTPClient(config)
+
+	for i := 0; i < 2; i++ {
+		resp, err := httpClient.Get(server.URL)
+		if assert.NoError(err) {
+			resp.Body.Close()
+			assert.Equal(500, resp.StatusCode)
+		}
+	}
+
+	// The circuit is open, so requests are rejected without reaching the server
+	_, err := httpClient.Get(server.URL)
+	assert.True(errors.Is(err, client.ErrCircuitOpen))
+	assert.Equal(int32(2), atomic.LoadInt32(&requests))
+
+	// Once the open timeout passes, a successful probe closes the circuit
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		resp, err := httpClient.Get(server.URL)
+		if assert.NoError(err) {
+			resp.Body.Close()
+			assert.Equal(200, resp.StatusCode)
+		}
+	}
+	assert.Equal(int32(4), atomic.LoadInt32(&requests))
+
+	// A probe abandoned by the caller releases its slot without closing the circuit
+	var failingRequests int32
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&failingRequests, 1) == 3 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(500)
+	}))
+	defer failingServer.Close()
+	for i := 0; i < 2; i++ {
+		resp, err := httpClient.Get(failingServer.URL)
+		if assert.NoError(err) {
+			resp.Body.Close()
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", failingServer.URL, nil)
+	_, err = httpClient.Do(req)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+
+	// The next probe is allowed and, as the host is still failing, reopens the circuit
+	resp, err := httpClient.Get(failingServer.URL)
+	if assert.NoError(err) {
+		resp.Body.Close()
+		assert.Equal(500, resp.StatusCode)
+	}
+	_, err = httpClient.Get(failingServer.URL)
+	assert.True(errors.Is(err, client.ErrCircuitOpen))
+	assert.Equal(int32(4), atomic.LoadInt32(&failingRequests))
+}
+
+func TestCircuitBreakerLateOutcome(t *testing.T) {
+	assert := assert.New(t)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+			return
+		}
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	config := makeConfig()
+	config.RetryConfig.MaxAttempts = 1
+	config.BreakerConfig = &client.BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenRequests: 1}
+	httpClient := client.NewHTTPClient(config)
+
+	// A slow request is allowed while the circuit is closed...
+	slowErr := make(chan error, 1)
+	go func() {
+		resp, err := httpClient.Get(server.URL + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		slowErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		resp, err := httpClient.Get(server.URL)
+		if assert.NoError(err) {
+			resp.Body.Close()
+		}
+	}
+
+	// ...and succeeds after the circuit opens, which does not close it again
+	close(release)
+	assert.NoError(<-slowErr)
+	_, err := httpClient.Get(server.URL)
+	assert.True(errors.Is(err, client.ErrCircuitOpen))
+}
+
+func TestPropagationAndLogging(t *testing.T) {
+	assert := assert.New(t)
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte("ok")) // nolint:errcheck
+	}))
+	defer server.Close()
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+	httpClient := client.NewHTTPClient(makeConfig())
+
+	// Make an outbound call from within an inbound server request
+	logs := &bytes.Buffer{}
+	inbound := hlog.NewHandler(zerolog.New(logs))(hlog.RequestIDHandler("req_id", "Request-Id")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := trace.ContextWithSpanContext(r.Context(), spanContext)
+			req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/downstream", nil)
+			resp, err := httpClient.Do(req)
+			if assert.NoError(err) {
+				resp.Body.Close()
+			}
+		})))
+	recorder := httptest.NewRecorder()
+	inbound.ServeHTTP(recorder, httptest.NewRequest("GET", "/upstream", nil))
+
+	reqID := recorder.Header().Get("Request-Id")
+	assert.NotEmpty(reqID)
+	assert.Equal(reqID, received.Get("Request-Id"))
+	assert.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", received.Get("traceparent"))
+
+	var clientLog map[string]interface{}
+	if assert.NoError(json.Unmarshal(logs.Bytes(), &clientLog)) {
+		assert.Equal("info", clientLog["level"])
+		assert.Equal(reqID, clientLog["req_id"])
+		assert.Equal("GET", clientLog["method"])
+		assert.Equal("HTTP/1.1", clientLog["proto"])
+		assert.Equal(server.URL+"/downstream", clientLog["url"])
+		assert.Equal(float64(200), clientLog["status"])
+		assert.Equal(float64(2), clientLog["size"])
+		assert.Contains(clientLog, "duration")
+		assert.Equal(float64(1), clientLog["attempt"])
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+)
+
+// HTTPClientConfig ...
+// Full configuration for outbound HTTP clients (see NewHTTPClient)
+//
+// See https://github.com/sethvargo/go-envconfig/blob/main/README.md
+type HTTPClientConfig struct {
+	// The deadline of each attempt, including reading the response body. A value of 0 disables the deadline.
+	Timeout time.Duration `env:"TIMEOUT,default=10s"`
+	// Transport timeouts and limits
+	// See https://pkg.go.dev/net/http#Transport
+	DialTimeout           time.Duration `env:"DIAL_TIMEOUT,default=5s"`
+	TLSHandshakeTimeout   time.Duration `env:"TLS_HANDSHAKE_TIMEOUT,default=5s"`
+	ResponseHeaderTimeout time.Duration `env:"RESPONSE_HEADER_TIMEOUT,default=10s"`
+	IdleConnTimeout       time.Duration `env:"IDLE_CONN_TIMEOUT,default=90s"`
+	MaxIdleConnsPerHost   int           `env:"MAX_IDLE_CONNS_PER_HOST,default=10"`
+
+	RetryConfig   *RetryConfig   `env:",prefix=RETRY_"`
+	BreakerConfig *BreakerConfig `env:",prefix=BREAKER_"`
+}
+
+// RetryConfig ...
+// Configuration used to retry failed requests with jittered exponential backoff. Only
+// idempotent requests (or requests with an Idempotency-Key header) are retried.
+//
+// Note: All env variables are prefixed with RETRY_ (see HTTPClientConfig)
+type RetryConfig struct {
+	// The maximum number of attempts per request. A value of 1 disables retries.
+	MaxAttempts int `env:"MAX_ATTEMPTS,default=3"`
+	// The backoff before the first retry, which grows by Multiplier on each subsequent retry
+	InitialBackoff time.Duration `env:"INITIAL_BACKOFF,default=100ms"`
+	MaxBackoff     time.Duration `env:"MAX_BACKOFF,default=5s"`
+	Multiplier     float64       `env:"MULTIPLIER,default=2"`
+	// The response statuses which are retried
+	Statuses []int `env:"STATUSES,default=429,502,503,504"`
+	// The longest Retry-After which is honored. Responses which ask for a longer wait are not retried.
+	MaxRetryAfter time.Duration `env:"MAX_RETRY_AFTER,default=30s"`
+}
+
+// BreakerConfig ...
+// Configuration used to stop sending requests to hosts which are failing. A host's
+// circuit opens after consecutive failures (transport errors or 5xx responses), rejects
+// requests while open, and then allows probe requests to decide whether to close again.
+//
+// Note: All env variables are prefixed with BREAKER_ (see HTTPClientConfig)
+type BreakerConfig struct {
+	// The number of consecutive failures which opens a host's circuit. A value of 0 disables the breaker.
+	FailureThreshold int `env:"FAILURE_THRESHOLD,default=5"`
+	// The time a circuit stays open before allowing probe requests
+	OpenTimeout time.Duration `env:"OPEN_TIMEOUT,default=30s"`
+	// The number of concurrent probe requests allowed while a circuit is half open
+	HalfOpenRequests int `env:"HALF_OPEN_REQUESTS,default=1"`
+}
+
+// NewHTTPClientConfig ...
+// Parses the client configuration from the given lookuper (e.g. env variables prefixed
+// with HTTP_CLIENT_ via envconfig.PrefixLookuper)
+func NewHTTPClientConfig(l envconfig.Lookuper) (*HTTPClientConfig, error) {
+	var config HTTPClientConfig
+	if err := envconfig.ProcessWith(context.Background(), &config, l); err != nil {
+		return nil, fmt.Errorf("HTTPClientConfig parse failure: %w", err)
+	}
+	return &config, nil
+}
+
+// Enabled ...
+// Returns true if requests may be retried
+func (c *RetryConfig) Enabled() bool {
+	return c != nil && c.MaxAttempts > 1
+}
+
+// Enabled ...
+// Returns true if the circuit breaker is enabled
+func (c *BreakerConfig) Enabled() bool {
+	return c != nil && c.FailureThreshold > 0
+}
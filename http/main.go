@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/rs/zerolog/log"
 	"github.com/sethvargo/go-envconfig"
 	"github.com/spals/starter-kit/http/server"
@@ -16,5 +21,39 @@ func main() {
 	}
 	log.Info().Msg("HTTPServer initialized")
 
-	httpServer.Start()
+	// Include a graceful server shutdown sequence
+	// See https://medium.com/honestbee-tw-engineer/gracefully-shutdown-in-go-http-server-5f5e6b83da5a#16fd
+	ctx, cancel := context.WithCancel(context.Background())
+	httpServerStopped := make(chan os.Signal, 1)
+	signal.Notify(httpServerStopped, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	// Include a zero-downtime binary upgrade sequence. On SIGUSR2, a new copy of this
+	// process is started and this process is shut down once the new one is ready.
+	httpServerUpgraded := make(chan os.Signal, 1)
+	signal.Notify(httpServerUpgraded, syscall.SIGUSR2)
+	go func() {
+		upgradeDone := make(chan error, 1)
+		for {
+			select {
+			case <-httpServerStopped:
+				cancel()
+				return
+			case <-httpServerUpgraded:
+				// Upgrade in the background so that stop signals are still handled while waiting on the new process
+				go func() {
+					upgradeDone <- httpServer.Upgrade()
+				}()
+			case err := <-upgradeDone:
+				if err != nil {
+					log.Error().Err(err).Msg("HTTPServer upgrade failure")
+					continue
+				}
+				cancel()
+				return
+			}
+		}
+	}()
+
+	if err := httpServer.Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("HTTPServer run failure")
+	}
 }
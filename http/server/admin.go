@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ActiveAdminAddr ...
+// Returns the address on which the admin server is actively listening, or nil if
+// the admin server is disabled or not yet listening.
+func (s *HTTPServer) ActiveAdminAddr() net.Addr {
+	listener := s.activeAdminListener()
+	if listener == nil {
+		return nil
+	}
+	return listener.Addr()
+}
+
+// ActiveAdminPort ...
+// Returns the port on which the admin server is actively listening, or 0 if the
+// admin server is disabled or not yet listening.
+func (s *HTTPServer) ActiveAdminPort() int {
+	if tcpAddr, ok := s.ActiveAdminAddr().(*net.TCPAddr); ok {
+		return tcpAddr.Port
+	}
+	return 0
+}
+
+// ========== Private Helpers ==========
+
+// Starts the admin server on the given listener, if enabled. Serve errors are sent to the given channel.
+//
+// Note that the admin server always serves plaintext HTTP/1.1 as it is meant to be
+// exposed only to internal networks (e.g. container health checks).
+func (s *HTTPServer) serveAdmin(listener net.Listener, serveErr chan<- error) {
+	if listener == nil {
+		return
+	}
+
+	s.setActiveAdminListener(listener)
+	go func() {
+		log.Info().Msgf("HTTPServer admin listening on %s:%s", listener.Addr().Network(), listener.Addr())
+		serveErr <- s.adminDelegate.Serve(listener)
+	}()
+}
+
+// A listener inherited from a parent process during a binary upgrade takes precedence.
+// Otherwise, the admin server listens on the configured admin port (or a random port).
+// Returns a nil listener if the admin server is disabled.
+func (s *HTTPServer) makeAdminListener() (net.Listener, error) {
+	if s.adminDelegate == nil {
+		return nil, nil
+	}
+
+	listener, err := inheritedListener(upgradeAdminListenerFDEnv)
+	if err != nil {
+		return nil, err
+	}
+	if listener == nil {
+		if listener, err = net.Listen("tcp", fmt.Sprint(":", s.config.AdminPort)); err != nil {
+			return nil, fmt.Errorf("error while listening on admin port (%d): %w", s.config.AdminPort, err)
+		}
+	}
+
+	newPort := listener.Addr().(*net.TCPAddr).Port
+	if s.config.AdminPort != newPort {
+		log.Info().Msgf("Overwriting configured admin port (%d) with active port (%d)", s.config.AdminPort, newPort)
+		s.config.AdminPort = newPort
+		s.config.SetRuntimeSource("AdminPort")
+	}
+	return listener, nil
+}
+
+func (s *HTTPServer) shutdownAdmin(ctx context.Context) error {
+	if s.adminDelegate == nil {
+		return nil
+	}
+	return s.shutdownDelegate(ctx, s.adminDelegate)
+}
+
+func (s *HTTPServer) activeAdminListener() net.Listener {
+	s.adminListenerMu.RLock()
+	defer s.adminListenerMu.RUnlock()
+	return s.adminListener
+}
+
+func (s *HTTPServer) setActiveAdminListener(listener net.Listener) {
+	s.adminListenerMu.Lock()
+	defer s.adminListenerMu.Unlock()
+	s.adminListener = listener
+}
@@ -0,0 +1,116 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminServer(t *testing.T) {
+	assert := assert.New(t)
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["ADMIN_PORT"] = "0"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+
+	status := func(port int, path string) int {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", port, path))
+		if err != nil {
+			return 0
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Eventually(func() bool {
+		return httpServer.ActiveAdminPort() != 0 && status(httpServer.ActiveAdminPort(), "/ready") == 200
+	}, serverStartTimeoutMs*time.Millisecond /*waitFor*/, serverStartTickMs*time.Millisecond /*tick*/)
+	assert.NotEqual(httpServer.ActivePort(), httpServer.ActiveAdminPort())
+
+	// Operational endpoints are only served by the admin server
+	for _, path := range []string{"/config", "/live", "/ready"} {
+		assert.Equal(200, status(httpServer.ActiveAdminPort(), path), path)
+		assert.Equal(404, status(httpServer.ActivePort(), path), path)
+	}
+
+	// Random ports are shown as the bound ports, overwritten at runtime
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/config", httpServer.ActiveAdminPort()))
+	if assert.NoError(err) {
+		var respConfig struct {
+			Config  map[string]interface{} `json:"config"`
+			Sources map[string]string      `json:"sources"`
+		}
+		assert.NoError(json.NewDecoder(resp.Body).Decode(&respConfig))
+		resp.Body.Close()
+		assert.Equal(float64(httpServer.ActiveAdminPort()), respConfig.Config["AdminPort"])
+		assert.Equal("runtime", respConfig.Sources["AdminPort"])
+		assert.Equal("runtime", respConfig.Sources["Port"])
+	}
+
+	// Admin requests are not recorded in the application request metrics
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/metrics", httpServer.ActiveAdminPort()))
+	if assert.NoError(err) {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Contains(string(body), "go_goroutines")
+		assert.NotContains(string(body), `route="/ready"`)
+	}
+
+	// Both servers share a single lifecycle
+	cancel()
+	assert.NoError(<-runErr)
+	assert.Equal(0, status(httpServer.ActivePort(), "/ready"))
+	assert.Equal(0, status(httpServer.ActiveAdminPort(), "/ready"))
+}
+
+func TestAdminServerDisabled(t *testing.T) {
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	urlBase := runTestServer(t, httpServer, &http.Client{}, "http")
+	assert.Nil(t, httpServer.ActiveAdminAddr())
+
+	resp, err := http.Get(fmt.Sprintf("%s/config", urlBase))
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+}
+
+func TestAdminServerBindFailure(t *testing.T) {
+	assert := assert.New(t)
+	// Occupy a port so that the admin server cannot bind to it
+	listener, err := net.Listen("tcp", ":0")
+	if !assert.NoError(err) {
+		return
+	}
+	defer listener.Close()
+
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["ADMIN_PORT"] = fmt.Sprint(listener.Addr().(*net.TCPAddr).Port)
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	assert.Error(httpServer.Run(context.Background()))
+	// The main server is stopped along with the admin server
+	port := httpServer.ActivePort()
+	if assert.NotZero(port) {
+		_, err := net.Dial("tcp", fmt.Sprint("localhost:", port))
+		assert.Error(err)
+	}
+}
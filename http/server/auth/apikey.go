@@ -0,0 +1,357 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spals/starter-kit/http/server/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Limits on the cost of verifying a presented secret against a hash in the key file
+const (
+	maxBcryptCost = 14
+	// The maximum argon2id memory in KiB (i.e. 128 MiB)
+	maxArgon2Memory  = 128 * 1024
+	maxArgon2Time    = 10
+	maxArgon2Threads = 16
+)
+
+// Failed verifications are tracked per key ID, so that a single caller cannot force an
+// unbounded number of expensive hash comparisons for a key ID
+const (
+	apiKeyFailureWindow = 10 * time.Second
+	apiKeyMaxFailures   = 10
+	// The maximum number of key IDs whose failures are tracked at once
+	apiKeyMaxTrackedFailures = 10000
+)
+
+// APIKeyVerifier ...
+// Verifies API keys against a file of hashed keys (see config.APIKeyConfig). The key
+// file is reloaded when it changes. If a reload fails, then the previous keys are kept.
+//
+// Note that API keys are verified before rate limits are applied (see middleware.RateLimit),
+// so the verifier bounds its own cost. Hash parameters are bounded when the key file is
+// loaded, and once a key ID has failed verification apiKeyMaxFailures times within
+// apiKeyFailureWindow, further unverified secrets for that ID are rejected without a
+// hash comparison until the window passes. Previously verified secrets are still accepted.
+type APIKeyVerifier struct {
+	file           string
+	headers        []string
+	reloadInterval time.Duration
+
+	mu      sync.RWMutex
+	keys    map[string]apiKey
+	modTime time.Time
+	size    int64
+	// The time at which the key file was last checked for changes
+	checkedAt time.Time
+	// SHA-256 digests of secrets which have been verified against their hash, keyed by key ID.
+	// This avoids repeating expensive hash comparisons on every request.
+	verified map[string][sha256.Size]byte
+	// A loaded hash which secrets presented with unknown key IDs are compared against, so that
+	// the response time does not reveal which key IDs exist
+	dummyHash string
+	// Recent failed verifications, keyed by presented key ID
+	failures map[string]*apiKeyFailures
+	// Set to 1 while a background reload is in progress
+	reloading int32
+}
+
+// A hashed API key
+type apiKey struct {
+	ID        string   `json:"id"`
+	Hash      string   `json:"hash"`
+	Principal string   `json:"principal"`
+	Scopes    []string `json:"scopes"`
+}
+
+// Failed verifications of a key ID within the current failure window
+type apiKeyFailures struct {
+	start time.Time
+	count int
+}
+
+// NewAPIKeyVerifier ...
+// Creates an APIKeyVerifier from the API key configuration. Returns nil if API key
+// authentication is not configured, or an error if the key file cannot be loaded.
+func NewAPIKeyVerifier(config *config.HTTPServerConfig) (*APIKeyVerifier, error) {
+	apiKeyConfig := config.APIKeyConfig
+	if !apiKeyConfig.Enabled() {
+		return nil, nil
+	}
+
+	verifier := &APIKeyVerifier{
+		file:           apiKeyConfig.File,
+		headers:        apiKeyConfig.Headers,
+		reloadInterval: apiKeyConfig.ReloadInterval,
+		failures:       make(map[string]*apiKeyFailures),
+	}
+	if err := verifier.load(); err != nil {
+		return nil, fmt.Errorf("API key configuration failure: %w", err)
+	}
+	return verifier, nil
+}
+
+// Verify ...
+// Verifies an API key of the form <id>.<secret> and returns the claims of its principal.
+// Note that returned errors never include the secret.
+func (v *APIKeyVerifier) Verify(key string) (*Claims, error) {
+	v.maybeReload()
+
+	id, secret, ok := strings.Cut(key, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, errors.New("malformed API key")
+	}
+
+	v.mu.RLock()
+	k, found := v.keys[id]
+	digest, cached := v.verified[id]
+	dummyHash := v.dummyHash
+	v.mu.RUnlock()
+
+	presented := sha256.Sum256([]byte(secret))
+	if found && cached && subtle.ConstantTimeCompare(presented[:], digest[:]) == 1 {
+		return &Claims{Subject: k.Principal, Scopes: k.Scopes, KeyID: k.ID}, nil
+	}
+	if v.throttled(id, time.Now()) {
+		return nil, fmt.Errorf("too many failed attempts for API key (%s)", id)
+	}
+	if !found {
+		// Spend the same time as a known key ID would
+		if dummyHash != "" {
+			verifyHash(dummyHash, secret) // nolint:errcheck
+		}
+		v.recordFailure(id, time.Now())
+		return nil, fmt.Errorf("unknown API key ID (%s)", id)
+	}
+
+	if err := verifyHash(k.Hash, secret); err != nil {
+		v.recordFailure(id, time.Now())
+		return nil, fmt.Errorf("invalid API key (%s): %w", id, err)
+	}
+	v.mu.Lock()
+	// Only cache the secret if the key has not been replaced by a reload
+	if current, ok := v.keys[id]; ok && current.Hash == k.Hash {
+		v.verified[id] = presented
+	}
+	v.mu.Unlock()
+
+	return &Claims{Subject: k.Principal, Scopes: k.Scopes, KeyID: k.ID}, nil
+}
+
+// ========== Private Helpers ==========
+
+// Returns the API key in the first configured header which is present, if any
+func (v *APIKeyVerifier) presentedKey(r *http.Request) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	for _, header := range v.headers {
+		if key := strings.TrimSpace(r.Header.Get(header)); key != "" {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// Returns true if the given key ID has failed verification too many times within the current window
+func (v *APIKeyVerifier) throttled(id string, now time.Time) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	f, ok := v.failures[id]
+	return ok && now.Sub(f.start) < apiKeyFailureWindow && f.count >= apiKeyMaxFailures
+}
+
+// Records a failed verification of the given key ID
+func (v *APIKeyVerifier) recordFailure(id string, now time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	f, ok := v.failures[id]
+	if !ok || now.Sub(f.start) >= apiKeyFailureWindow {
+		if !ok && len(v.failures) >= apiKeyMaxTrackedFailures {
+			v.pruneFailures(now)
+		}
+		f = &apiKeyFailures{start: now}
+		v.failures[id] = f
+	}
+	f.count++
+}
+
+// Removes expired failure windows, or all failure windows if none have expired. The
+// caller must hold the write lock.
+func (v *APIKeyVerifier) pruneFailures(now time.Time) {
+	for id, f := range v.failures {
+		if now.Sub(f.start) >= apiKeyFailureWindow {
+			delete(v.failures, id)
+		}
+	}
+	if len(v.failures) >= apiKeyMaxTrackedFailures {
+		v.failures = make(map[string]*apiKeyFailures)
+	}
+}
+
+// Reloads the key file in the background if the reload interval has elapsed and the file has changed
+func (v *APIKeyVerifier) maybeReload() {
+	v.mu.RLock()
+	stale := time.Since(v.checkedAt) >= v.reloadInterval
+	v.mu.RUnlock()
+	if !stale || !atomic.CompareAndSwapInt32(&v.reloading, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&v.reloading, 0)
+		info, err := os.Stat(v.file)
+		v.mu.Lock()
+		v.checkedAt = time.Now()
+		changed := err != nil || !info.ModTime().Equal(v.modTime) || info.Size() != v.size
+		v.mu.Unlock()
+		if !changed {
+			return
+		}
+		if err := v.load(); err != nil {
+			log.Error().Err(err).Msg("Error while reloading API keys. Keeping previous keys")
+		}
+	}()
+}
+
+func (v *APIKeyVerifier) load() error {
+	info, err := os.Stat(v.file)
+	if err != nil {
+		return fmt.Errorf("error while reading API key file (%s): %w", v.file, err)
+	}
+	data, err := os.ReadFile(v.file)
+	if err != nil {
+		return fmt.Errorf("error while reading API key file (%s): %w", v.file, err)
+	}
+
+	var keyFile struct {
+		Keys []apiKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &keyFile); err != nil {
+		return fmt.Errorf("error while parsing API key file (%s): %w", v.file, err)
+	}
+	keys := make(map[string]apiKey, len(keyFile.Keys))
+	var dummyHash string
+	for _, k := range keyFile.Keys {
+		if k.ID == "" || strings.Contains(k.ID, ".") {
+			return fmt.Errorf("invalid API key ID (%s): IDs must be non-empty and must not contain '.'", k.ID)
+		}
+		if _, ok := keys[k.ID]; ok {
+			return fmt.Errorf("duplicate API key ID (%s)", k.ID)
+		}
+		if err := validateHash(k.Hash); err != nil {
+			return fmt.Errorf("invalid hash for API key (%s): %w", k.ID, err)
+		}
+		if k.Principal == "" {
+			k.Principal = k.ID
+		}
+		keys[k.ID] = k
+		if dummyHash == "" {
+			dummyHash = k.Hash
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+	v.verified = make(map[string][sha256.Size]byte)
+	v.dummyHash = dummyHash
+	v.modTime = info.ModTime()
+	v.size = info.Size()
+	v.checkedAt = time.Now()
+	log.Debug().Int("keys", len(keys)).Msg("Loaded API keys")
+	return nil
+}
+
+// Returns an error if the given hash is not a supported bcrypt or argon2id hash, or if
+// verifying a secret against it would be too expensive
+func validateHash(hash string) error {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, _, err := parseArgon2idHash(hash)
+		if err != nil {
+			return err
+		}
+		if params.time < 1 || params.time > maxArgon2Time {
+			return fmt.Errorf("argon2id time must be between 1 and %d (got %d)", maxArgon2Time, params.time)
+		}
+		if params.threads < 1 || params.threads > maxArgon2Threads {
+			return fmt.Errorf("argon2id parallelism must be between 1 and %d (got %d)", maxArgon2Threads, params.threads)
+		}
+		if params.memory < 8*uint32(params.threads) || params.memory > maxArgon2Memory {
+			return fmt.Errorf("argon2id memory must be between %d and %d KiB (got %d)", 8*uint32(params.threads), maxArgon2Memory, params.memory)
+		}
+		return nil
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return err
+	}
+	if cost > maxBcryptCost {
+		return fmt.Errorf("bcrypt cost must be at most %d (got %d)", maxBcryptCost, cost)
+	}
+	return nil
+}
+
+// Returns nil if the given secret matches the given bcrypt or argon2id hash
+func verifyHash(hash string, secret string) error {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret))
+	}
+
+	params, key, err := parseArgon2idHash(hash)
+	if err != nil {
+		return err
+	}
+	derived := argon2.IDKey([]byte(secret), params.salt, params.time, params.memory, params.threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return errors.New("secret does not match hash")
+	}
+	return nil
+}
+
+type argon2idParams struct {
+	salt    []byte
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// Parses an argon2id hash in PHC string format (e.g. $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>)
+func parseArgon2idHash(hash string) (argon2idParams, []byte, error) {
+	var params argon2idParams
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, fmt.Errorf("unsupported argon2id version (%s)", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, fmt.Errorf("malformed argon2id parameters (%s)", parts[3])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, errors.New("malformed argon2id salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, errors.New("malformed argon2id key")
+	}
+	params.salt = salt
+	return params, key, nil
+}
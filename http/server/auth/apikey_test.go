@@ -0,0 +1,275 @@
+package auth_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spals/starter-kit/http/server/auth"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/internal/servertest"
+	"github.com/spals/starter-kit/http/server/route"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewAPIKeyVerifierDisabled(t *testing.T) {
+	verifier, err := auth.NewAPIKeyVerifier(&config.HTTPServerConfig{APIKeyConfig: &config.APIKeyConfig{}})
+	assert.NoError(t, err)
+	assert.Nil(t, verifier)
+}
+
+func TestNewAPIKeyVerifierInvalidFile(t *testing.T) {
+	testCases := map[string]string{
+		"malformed":    `{"keys": [`,
+		"missing ID":   `{"keys": [{"hash": "$2a$04$abcdefghijklmnopqrstuv"}]}`,
+		"dotted ID":    fmt.Sprintf(`{"keys": [{"id": "a.b", "hash": %q}]}`, bcryptHash(t, "secret")),
+		"duplicate ID": fmt.Sprintf(`{"keys": [{"id": "a", "hash": %q}, {"id": "a", "hash": %q}]}`, bcryptHash(t, "secret"), bcryptHash(t, "secret")),
+		"plain secret": `{"keys": [{"id": "a", "hash": "secret"}]}`,
+		"bad argon2id": `{"keys": [{"id": "a", "hash": "$argon2id$v=19$m=64,t=1$salt$key"}]}`,
+		"expensive argon2id": fmt.Sprintf(`{"keys": [{"id": "a", "hash": %q}]}`,
+			strings.Replace(argon2idHash(t, "secret"), "m=64,t=1,p=1", "m=4194304,t=1,p=1", 1)),
+		"expensive bcrypt": fmt.Sprintf(`{"keys": [{"id": "a", "hash": %q}]}`, strings.Replace(bcryptHash(t, "secret"), "$04$", "$20$", 1)),
+	}
+
+	for name, contents := range testCases {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "keys.json")
+			assert.NoError(t, os.WriteFile(file, []byte(contents), 0600))
+			_, err := auth.NewAPIKeyVerifier(&config.HTTPServerConfig{APIKeyConfig: &config.APIKeyConfig{File: file}})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAPIKeyVerifierVerify(t *testing.T) {
+	verifier := newAPIKeyVerifier(t, writeAPIKeyFile(t,
+		apiKeyEntry{ID: "ci", Hash: bcryptHash(t, "bcrypt-secret"), Principal: "ci-pipeline", Scopes: []string{"read"}},
+		apiKeyEntry{ID: "batch", Hash: argon2idHash(t, "argon2-secret"), Scopes: []string{"read", "write"}},
+	), time.Hour)
+
+	testCases := map[string]struct {
+		key       string
+		valid     bool
+		principal string
+		scopes    []string
+	}{
+		"bcrypt":              {"ci.bcrypt-secret", true, "ci-pipeline", []string{"read"}},
+		"bcrypt cached":       {"ci.bcrypt-secret", true, "ci-pipeline", []string{"read"}},
+		"argon2id":            {"batch.argon2-secret", true, "batch", []string{"read", "write"}},
+		"wrong bcrypt secret": {"ci.wrong", false, "", nil},
+		"wrong argon2 secret": {"batch.wrong", false, "", nil},
+		"unknown ID":          {"other.bcrypt-secret", false, "", nil},
+		"missing ID":          {"bcrypt-secret", false, "", nil},
+		"missing secret":      {"ci.", false, "", nil},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			claims, err := verifier.Verify(tc.key)
+			if tc.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.principal, claims.Subject)
+				assert.Equal(t, tc.scopes, claims.Scopes)
+			} else {
+				assert.Error(t, err)
+				if _, secret, _ := strings.Cut(tc.key, "."); secret != "" {
+					assert.NotContains(t, err.Error(), secret)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyVerifierThrottle(t *testing.T) {
+	assert := assert.New(t)
+	verifier := newAPIKeyVerifier(t, writeAPIKeyFile(t,
+		apiKeyEntry{ID: "ci", Hash: bcryptHash(t, "secret")},
+		apiKeyEntry{ID: "batch", Hash: bcryptHash(t, "other-secret")},
+	), time.Hour)
+	_, err := verifier.Verify("batch.other-secret")
+	assert.NoError(err)
+
+	for _, id := range []string{"ci", "batch", "unknown"} {
+		for i := 0; i < 10; i++ {
+			_, err := verifier.Verify(id + ".wrong")
+			if assert.Error(err) {
+				assert.NotContains(err.Error(), "too many")
+			}
+		}
+	}
+
+	// Once a key ID has failed too often, unverified secrets are rejected without a hash comparison
+	_, err = verifier.Verify("ci.secret")
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "too many failed attempts")
+	}
+	_, err = verifier.Verify("unknown.secret")
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "too many failed attempts")
+	}
+	// Previously verified secrets are still accepted
+	_, err = verifier.Verify("batch.other-secret")
+	assert.NoError(err)
+}
+
+func TestAPIKeyVerifierReload(t *testing.T) {
+	file := writeAPIKeyFile(t, apiKeyEntry{ID: "ci", Hash: bcryptHash(t, "old-secret")})
+	verifier := newAPIKeyVerifier(t, file, 10*time.Millisecond)
+	_, err := verifier.Verify("ci.old-secret")
+	assert.NoError(t, err)
+
+	// Rotate the key. The file is reloaded in the background once the reload interval elapses.
+	data, _ := json.Marshal(map[string][]apiKeyEntry{"keys": {{ID: "ci", Hash: bcryptHash(t, "new-secret")}}})
+	assert.NoError(t, os.WriteFile(file, data, 0600))
+	assert.NoError(t, os.Chtimes(file, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	assert.Eventually(t, func() bool {
+		_, err := verifier.Verify("ci.new-secret")
+		return err == nil
+	}, time.Second, 20*time.Millisecond)
+	_, err = verifier.Verify("ci.old-secret")
+	assert.Error(t, err)
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	verifier := newAPIKeyVerifier(t, writeAPIKeyFile(t,
+		apiKeyEntry{ID: "ci", Hash: bcryptHash(t, "secret"), Principal: "ci-pipeline", Scopes: []string{"read"}},
+	), time.Hour)
+	var logs bytes.Buffer
+	handler := makeAPIKeyRouter(&logs, verifier)
+
+	testCases := map[string]struct {
+		path       string
+		header     string
+		key        string
+		expected   int
+		challenges []string
+	}{
+		"missing key":   {"/private", "", "", http.StatusUnauthorized, []string{`APIKey realm="starter-kit-http"`}},
+		"invalid key":   {"/private", "X-API-Key", "ci.wrong", http.StatusUnauthorized, []string{`APIKey realm="starter-kit-http", error="invalid_token", error_description="The API key is invalid"`}},
+		"valid key":     {"/private", "X-API-Key", "ci.secret", http.StatusOK, nil},
+		"other header":  {"/private", "X-Service-Key", "ci.secret", http.StatusOK, nil},
+		"scoped route":  {"/read", "X-API-Key", "ci.secret", http.StatusOK, nil},
+		"missing scope": {"/admin", "X-API-Key", "ci.secret", http.StatusForbidden, []string{`APIKey realm="starter-kit-http", error="insufficient_scope", scope="admin"`}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.key)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.expected, recorder.Code)
+			assert.Equal(t, tc.challenges, recorder.Header().Values("WWW-Authenticate"))
+			assert.NotContains(t, logs.String(), "secret")
+			if tc.expected == http.StatusOK {
+				assert.Contains(t, logs.String(), `"api_key_id":"ci"`)
+				assert.Contains(t, logs.String(), `"auth_subject":"ci-pipeline"`)
+			}
+		})
+	}
+}
+
+func TestAuthenticateOffersAllSchemes(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	jwtVerifier := newJWTVerifier(t, &config.JWTConfig{JWKSFile: writeJWKSFile(t, key), JWKSRefreshInterval: time.Hour, Algorithms: []string{"RS256"}, Realm: "test"})
+	apiKeyVerifier := newAPIKeyVerifier(t, writeAPIKeyFile(t, apiKeyEntry{ID: "ci", Hash: bcryptHash(t, "secret")}), time.Hour)
+
+	router := mux.NewRouter()
+	route.Register(router, route.Group{
+		Routes: []route.Route{{Name: "private", Path: "/private", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), Authenticated: true}},
+	})
+	router.Use(auth.Authenticate(jwtVerifier, apiKeyVerifier))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/private", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, []string{`Bearer realm="test"`, `APIKey realm="test"`}, recorder.Header().Values("WWW-Authenticate"))
+
+	for _, credentials := range []map[string]string{
+		{"Authorization": "Bearer " + key.sign(t, validClaims())},
+		{"X-API-Key": "ci.secret"},
+	} {
+		req := httptest.NewRequest("GET", "/private", nil)
+		for header, value := range credentials {
+			req.Header.Set(header, value)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+}
+
+// ========== Private Helpers ==========
+
+// An entry in an API key file
+type apiKeyEntry struct {
+	ID        string   `json:"id"`
+	Hash      string   `json:"hash"`
+	Principal string   `json:"principal,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+}
+
+func writeAPIKeyFile(t *testing.T, keys ...apiKeyEntry) string {
+	data, err := json.Marshal(map[string][]apiKeyEntry{"keys": keys})
+	assert.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "keys.json")
+	assert.NoError(t, os.WriteFile(file, data, 0600))
+	return file
+}
+
+func newAPIKeyVerifier(t *testing.T, file string, reloadInterval time.Duration) *auth.APIKeyVerifier {
+	verifier, err := auth.NewAPIKeyVerifier(&config.HTTPServerConfig{APIKeyConfig: &config.APIKeyConfig{
+		File:           file,
+		Headers:        []string{"X-API-Key", "X-Service-Key"},
+		ReloadInterval: reloadInterval,
+	}})
+	assert.NoError(t, err)
+	return verifier
+}
+
+func bcryptHash(t *testing.T, secret string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+	assert.NoError(t, err)
+	return string(hash)
+}
+
+func argon2idHash(t *testing.T, secret string) string {
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	assert.NoError(t, err)
+	key := argon2.IDKey([]byte(secret), salt, 1 /*time*/, 64 /*memory*/, 1 /*threads*/, 32 /*keyLen*/)
+	return fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// Creates a router with authenticated and scoped routes, wrapped in a request logger
+// and access log which write to the given writer
+func makeAPIKeyRouter(logs io.Writer, verifier *auth.APIKeyVerifier) http.Handler {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	router := servertest.Router(route.Group{
+		Routes: []route.Route{
+			{Name: "private", Path: "/private", Handler: ok, Authenticated: true},
+			{Name: "read", Path: "/read", Handler: ok, Scopes: []string{"read"}},
+			{Name: "admin", Path: "/admin", Handler: ok, Scopes: []string{"admin"}},
+		},
+	}, auth.Authenticate(nil /*jwtVerifier*/, verifier))
+	return servertest.Handler(logs, router)
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/spals/starter-kit/http/server/problem"
+	"github.com/spals/starter-kit/http/server/route"
+)
+
+// The realm used in WWW-Authenticate challenges if JWT authentication is not configured
+const defaultRealm = "starter-kit-http"
+
+// Authentication schemes used in WWW-Authenticate challenges
+const (
+	bearerScheme = "Bearer"
+	apiKeyScheme = "APIKey"
+)
+
+// Authenticate ...
+// Middleware which authenticates requests to routes which require it (see route.Route and
+// route.Current) and stores the caller's claims in the request context (see ClaimsFromContext).
+// Either verifier may be nil if its authentication method is not configured.
+func Authenticate(jwtVerifier *JWTVerifier, apiKeyVerifier *APIKeyVerifier) mux.MiddlewareFunc {
+	realm := defaultRealm
+	if jwtVerifier != nil {
+		realm = jwtVerifier.realm
+	}
+	// The schemes offered to callers which present no credentials. Fail closed with a
+	// bearer challenge if a route requires authentication but none is configured.
+	var schemes []string
+	if jwtVerifier != nil || apiKeyVerifier == nil {
+		schemes = append(schemes, bearerScheme)
+	}
+	if apiKeyVerifier != nil {
+		schemes = append(schemes, apiKeyScheme)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			muxRoute := mux.CurrentRoute(r)
+			var pathTemplate string
+			if muxRoute != nil {
+				pathTemplate, _ = muxRoute.GetPathTemplate()
+			}
+			registeredRoute, ok := route.Current(r)
+			if !ok && muxRoute != nil {
+				// Fail closed, as the authentication requirements of the route are unknown
+				hlog.FromRequest(r).Error().
+					Str("route", pathTemplate).
+					Msg("Rejected request to a route which was not registered via route.Register")
+				problem.Write(w, r, problem.New(http.StatusInternalServerError, "").With("route", pathTemplate))
+				return
+			}
+			if !registeredRoute.Authenticated && len(registeredRoute.Scopes) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reject := func(status int, challenges []string, detail string, reason string, err error) {
+				hlog.FromRequest(r).Warn().
+					Err(err).
+					Str("route", pathTemplate).
+					Str("route_name", registeredRoute.Name).
+					Str("reason", reason).
+					Msg("Rejected unauthorized request")
+				for _, challenge := range challenges {
+					w.Header().Add("WWW-Authenticate", challenge)
+				}
+				problem.Write(w, r, problem.New(status, detail).With("route", pathTemplate))
+			}
+
+			var claims *Claims
+			var scheme string
+			if key, ok := apiKeyVerifier.presentedKey(r); ok {
+				scheme = apiKeyScheme
+				var err error
+				if claims, err = apiKeyVerifier.Verify(key); err != nil {
+					reject(http.StatusUnauthorized,
+						[]string{fmt.Sprintf("%s realm=%q, error=\"invalid_token\", error_description=\"The API key is invalid\"", apiKeyScheme, realm)},
+						"The API key is invalid", "invalid API key", err)
+					return
+				}
+			} else if token, ok := bearerToken(r); ok && jwtVerifier != nil {
+				scheme = bearerScheme
+				var err error
+				if claims, err = jwtVerifier.Verify(token); err != nil {
+					description := "The access token is invalid"
+					if errors.Is(err, jwt.ErrTokenExpired) {
+						description = "The access token expired"
+					}
+					reject(http.StatusUnauthorized,
+						[]string{fmt.Sprintf("%s realm=%q, error=\"invalid_token\", error_description=%q", bearerScheme, realm, description)},
+						description, "invalid bearer token", err)
+					return
+				}
+			} else {
+				challenges := make([]string, len(schemes))
+				for i, s := range schemes {
+					challenges[i] = fmt.Sprintf("%s realm=%q", s, realm)
+				}
+				reject(http.StatusUnauthorized, challenges, "Authentication is required", "missing credentials", nil)
+				return
+			}
+
+			zerolog.Ctx(r.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+				c = c.Str("auth_subject", claims.Subject)
+				if claims.KeyID != "" {
+					c = c.Str("api_key_id", claims.KeyID)
+				}
+				return c
+			})
+			if !claims.HasScopes(registeredRoute.Scopes...) {
+				reject(http.StatusForbidden,
+					[]string{fmt.Sprintf("%s realm=%q, error=\"insufficient_scope\", scope=%q", scheme, realm, strings.Join(registeredRoute.Scopes, " "))},
+					fmt.Sprintf("Missing required scopes (%s)", strings.Join(registeredRoute.Scopes, " ")), "insufficient scope", nil)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+		})
+	}
+}
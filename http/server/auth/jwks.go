@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// The maximum size of a JSON Web Key Set fetched from a URL
+const maxJWKSBytes = 1 << 20
+
+// A JSON Web Key Set loaded from a file or URL. The key set is lazily reloaded at
+// most once per refresh interval. Reloads happen in the background so that requests
+// are never blocked on fetching keys. If a reload fails, then the previous keys are kept.
+type keySet struct {
+	file            string
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mu       sync.RWMutex
+	keys     []jose.JSONWebKey
+	loadedAt time.Time
+	// Set to 1 while a background reload is in progress
+	reloading int32
+}
+
+func newKeySet(file string, url string, refreshInterval time.Duration) (*keySet, error) {
+	ks := &keySet{file: file, url: url, refreshInterval: refreshInterval, client: &http.Client{Timeout: 10 * time.Second}}
+	if err := ks.load(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Returns the public verification key with the given key ID. If the token has no
+// key ID, then the only key in the set is returned.
+func (ks *keySet) key(kid string) (interface{}, error) {
+	ks.maybeReload()
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if kid == "" {
+		if len(ks.keys) == 1 {
+			return ks.keys[0].Key, nil
+		}
+		return nil, errors.New("token has no key ID and the key set has multiple keys")
+	}
+	for _, k := range ks.keys {
+		if k.KeyID == kid {
+			return k.Key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key ID (%s)", kid)
+}
+
+// Reloads the key set in the background if the refresh interval has elapsed
+func (ks *keySet) maybeReload() {
+	ks.mu.RLock()
+	stale := time.Since(ks.loadedAt) >= ks.refreshInterval
+	ks.mu.RUnlock()
+	if !stale || !atomic.CompareAndSwapInt32(&ks.reloading, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&ks.reloading, 0)
+		if err := ks.load(); err != nil {
+			log.Error().Err(err).Msg("Error while reloading JWKS. Keeping previous keys")
+			// Wait another refresh interval before retrying
+			ks.mu.Lock()
+			ks.loadedAt = time.Now()
+			ks.mu.Unlock()
+		}
+	}()
+}
+
+func (ks *keySet) load() error {
+	data, err := ks.read()
+	if err != nil {
+		return err
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return fmt.Errorf("error while parsing JWKS: %w", err)
+	}
+	// Only public signing keys are used to verify tokens
+	keys := make([]jose.JSONWebKey, 0, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.IsPublic() && k.Use != "enc" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("JWKS contains no public signing keys")
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+	ks.loadedAt = time.Now()
+	log.Debug().Int("keys", len(keys)).Msg("Loaded JWKS")
+	return nil
+}
+
+func (ks *keySet) read() ([]byte, error) {
+	if ks.file != "" {
+		data, err := os.ReadFile(ks.file)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading JWKS file (%s): %w", ks.file, err)
+		}
+		return data, nil
+	}
+
+	resp, err := ks.client.Get(ks.url)
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching JWKS (%s): %w", ks.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error while fetching JWKS (%s): unexpected status %d", ks.url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching JWKS (%s): %w", ks.url, err)
+	}
+	return data, nil
+}
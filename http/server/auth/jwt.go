@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spals/starter-kit/http/server/config"
+)
+
+// Claims ...
+// The verified claims of an authenticated caller, from either a JWT bearer token or an API key
+type Claims struct {
+	// The token subject, or the principal of the API key
+	Subject  string
+	Issuer   string
+	Audience []string
+	// The scopes granted to the caller. For tokens, these are read from either the
+	// scope (space delimited) or scp claim.
+	Scopes []string
+	// The ID of the API key which authenticated the caller, if any
+	KeyID string
+	// All claims in the token, if any
+	Raw map[string]interface{}
+}
+
+type claimsKey struct{}
+
+// HasScopes ...
+// Returns true if the claims grant all of the given scopes
+func (c *Claims) HasScopes(scopes ...string) bool {
+	return hasScopes(c.Scopes, scopes)
+}
+
+// ClaimsFromContext ...
+// Returns the verified JWT claims stored in the given context, if any
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// WithClaims ...
+// Returns a copy of the given context which holds the given claims
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// JWTVerifier ...
+// Verifies JWT bearer tokens against a JSON Web Key Set loaded from a file or URL
+type JWTVerifier struct {
+	keySet *keySet
+	parser *jwt.Parser
+	realm  string
+}
+
+// NewJWTVerifier ...
+// Creates a JWTVerifier from the JWT configuration. Returns nil if JWT authentication
+// is not configured, or an error if the key set cannot be loaded.
+func NewJWTVerifier(config *config.HTTPServerConfig) (*JWTVerifier, error) {
+	jwtConfig := config.JWTConfig
+	if !jwtConfig.Enabled() {
+		return nil, nil
+	}
+
+	keySet, err := newKeySet(jwtConfig.JWKSFile, jwtConfig.JWKSURL, jwtConfig.JWKSRefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("JWT configuration failure: %w", err)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtConfig.Algorithms),
+		jwt.WithLeeway(jwtConfig.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if jwtConfig.Issuer != "" {
+		options = append(options, jwt.WithIssuer(jwtConfig.Issuer))
+	}
+	if jwtConfig.Audience != "" {
+		options = append(options, jwt.WithAudience(jwtConfig.Audience))
+	}
+	return &JWTVerifier{keySet: keySet, parser: jwt.NewParser(options...), realm: jwtConfig.Realm}, nil
+}
+
+// Verify ...
+// Verifies the signature and the iss, aud, exp, nbf and iat claims of the given token.
+// Note that returned errors never include the token itself.
+func (v *JWTVerifier) Verify(token string) (*Claims, error) {
+	mapClaims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, mapClaims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keySet.key(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{Raw: mapClaims, Scopes: scopesClaim(mapClaims)}
+	claims.Subject, _ = mapClaims.GetSubject()
+	claims.Issuer, _ = mapClaims.GetIssuer()
+	claims.Audience, _ = mapClaims.GetAudience()
+	return claims, nil
+}
+
+// ========== Private Helpers ==========
+
+// Returns the scopes in the scope claim (RFC 8693) or the scp claim
+func scopesClaim(claims jwt.MapClaims) []string {
+	for _, name := range []string{"scope", "scp"} {
+		switch value := claims[name].(type) {
+		case string:
+			return strings.Fields(value)
+		case []interface{}:
+			scopes := make([]string, 0, len(value))
+			for _, v := range value {
+				if s, ok := v.(string); ok {
+					scopes = append(scopes, s)
+				}
+			}
+			return scopes
+		}
+	}
+	return nil
+}
+
+// Returns true if the granted scopes include all of the required scopes
+func hasScopes(granted []string, required []string) bool {
+	for _, r := range required {
+		found := false
+		for _, g := range granted {
+			if g == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
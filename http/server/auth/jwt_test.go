@@ -0,0 +1,286 @@
+package auth_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spals/starter-kit/http/server/auth"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/internal/servertest"
+	"github.com/spals/starter-kit/http/server/route"
+	"github.com/stretchr/testify/assert"
+)
+
+// An RSA signing key identified by a key ID
+type testKey struct {
+	kid        string
+	privateKey *rsa.PrivateKey
+}
+
+func newTestKey(t *testing.T, kid string) testKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return testKey{kid: kid, privateKey: privateKey}
+}
+
+func jwks(t *testing.T, keys ...testKey) []byte {
+	var jwks jose.JSONWebKeySet
+	for _, k := range keys {
+		jwks.Keys = append(jwks.Keys, jose.JSONWebKey{Key: &k.privateKey.PublicKey, KeyID: k.kid, Algorithm: "RS256", Use: "sig"})
+	}
+	data, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+	return data
+}
+
+func writeJWKSFile(t *testing.T, keys ...testKey) string {
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(file, jwks(t, keys...), 0600))
+	return file
+}
+
+func (k testKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.privateKey)
+	assert.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":   "user",
+		"iss":   "https://issuer.example.com",
+		"aud":   "starter-kit",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"scope": "read write",
+	}
+}
+
+func newJWTVerifier(t *testing.T, jwtConfig *config.JWTConfig) *auth.JWTVerifier {
+	verifier, err := auth.NewJWTVerifier(&config.HTTPServerConfig{JWTConfig: jwtConfig})
+	assert.NoError(t, err)
+	return verifier
+}
+
+func TestNewJWTVerifierDisabled(t *testing.T) {
+	verifier, err := auth.NewJWTVerifier(&config.HTTPServerConfig{JWTConfig: &config.JWTConfig{}})
+	assert.NoError(t, err)
+	assert.Nil(t, verifier)
+}
+
+func TestNewJWTVerifierInvalidJWKS(t *testing.T) {
+	_, err := auth.NewJWTVerifier(&config.HTTPServerConfig{JWTConfig: &config.JWTConfig{JWKSFile: filepath.Join(t.TempDir(), "missing.json")}})
+	assert.Error(t, err)
+}
+
+func TestJWTVerifierVerify(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	otherKey := newTestKey(t, "key-2")
+	verifier := newJWTVerifier(t, &config.JWTConfig{
+		JWKSFile:            writeJWKSFile(t, key),
+		JWKSRefreshInterval: time.Hour,
+		Issuer:              "https://issuer.example.com",
+		Audience:            "starter-kit",
+		ClockSkew:           30 * time.Second,
+		Algorithms:          []string{"RS256"},
+	})
+
+	withClaim := func(name string, value interface{}) jwt.MapClaims {
+		claims := validClaims()
+		claims[name] = value
+		return claims
+	}
+	testCases := map[string]struct {
+		token string
+		valid bool
+	}{
+		"valid":                 {key.sign(t, validClaims()), true},
+		"expired within skew":   {key.sign(t, withClaim("exp", time.Now().Add(-10*time.Second).Unix())), true},
+		"expired beyond skew":   {key.sign(t, withClaim("exp", time.Now().Add(-time.Minute).Unix())), false},
+		"missing exp":           {key.sign(t, withClaim("exp", nil)), false},
+		"not yet valid":         {key.sign(t, withClaim("nbf", time.Now().Add(time.Minute).Unix())), false},
+		"wrong issuer":          {key.sign(t, withClaim("iss", "https://other.example.com")), false},
+		"wrong audience":        {key.sign(t, withClaim("aud", "other")), false},
+		"audience list":         {key.sign(t, withClaim("aud", []string{"other", "starter-kit"})), true},
+		"unknown key":           {otherKey.sign(t, validClaims()), false},
+		"malformed":             {"not-a-token", false},
+		"unsupported algorithm": {signHS256(t, validClaims()), false},
+		"tampered signature":    {key.sign(t, validClaims()) + "x", false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			claims, err := verifier.Verify(tc.token)
+			if tc.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, "user", claims.Subject)
+				assert.Equal(t, []string{"read", "write"}, claims.Scopes)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestJWTVerifierScpClaim(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	verifier := newJWTVerifier(t, &config.JWTConfig{JWKSFile: writeJWKSFile(t, key), JWKSRefreshInterval: time.Hour, Algorithms: []string{"RS256"}})
+
+	claims := validClaims()
+	delete(claims, "scope")
+	claims["scp"] = []string{"admin"}
+	verified, err := verifier.Verify(key.sign(t, claims))
+	assert.NoError(t, err)
+	assert.True(t, verified.HasScopes("admin"))
+	assert.False(t, verified.HasScopes("admin", "read"))
+}
+
+func TestJWTVerifierJWKSURLRefresh(t *testing.T) {
+	oldKey := newTestKey(t, "old")
+	newKey := newTestKey(t, "new")
+	var current atomic.Value
+	current.Store(jwks(t, oldKey))
+	var fetches int32
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write(current.Load().([]byte)) // nolint:errcheck
+	}))
+	defer jwksServer.Close()
+
+	verifier := newJWTVerifier(t, &config.JWTConfig{JWKSURL: jwksServer.URL, JWKSRefreshInterval: 10 * time.Millisecond, Algorithms: []string{"RS256"}})
+	_, err := verifier.Verify(oldKey.sign(t, validClaims()))
+	assert.NoError(t, err)
+
+	// Rotate keys. The key set is reloaded in the background once the refresh interval elapses.
+	current.Store(jwks(t, newKey))
+	assert.Eventually(t, func() bool {
+		_, err := verifier.Verify(newKey.sign(t, validClaims()))
+		return err == nil
+	}, time.Second, 20*time.Millisecond)
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&fetches), int32(2))
+}
+
+func TestAuthenticate(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	verifier := newJWTVerifier(t, &config.JWTConfig{
+		JWKSFile:            writeJWKSFile(t, key),
+		JWKSRefreshInterval: time.Hour,
+		ClockSkew:           30 * time.Second,
+		Algorithms:          []string{"RS256"},
+		Realm:               "test",
+	})
+	var logs bytes.Buffer
+	handler := makeAuthRouter(&logs, verifier)
+
+	adminClaims := validClaims()
+	adminClaims["scope"] = "admin"
+	expiredClaims := validClaims()
+	expiredClaims["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	testCases := map[string]struct {
+		path      string
+		token     string
+		expected  int
+		challenge string
+	}{
+		"public route":               {"/public", "", http.StatusOK, ""},
+		"missing token":              {"/private", "", http.StatusUnauthorized, `Bearer realm="test"`},
+		"invalid token":              {"/private", "not-a-token", http.StatusUnauthorized, `Bearer realm="test", error="invalid_token", error_description="The access token is invalid"`},
+		"expired token":              {"/private", key.sign(t, expiredClaims), http.StatusUnauthorized, `Bearer realm="test", error="invalid_token", error_description="The access token expired"`},
+		"valid token":                {"/private", key.sign(t, validClaims()), http.StatusOK, ""},
+		"scoped route":               {"/admin", key.sign(t, adminClaims), http.StatusOK, ""},
+		"scoped route missing scope": {"/admin", key.sign(t, validClaims()), http.StatusForbidden, `Bearer realm="test", error="insufficient_scope", scope="admin"`},
+		"unregistered route":         {"/unregistered", key.sign(t, validClaims()), http.StatusInternalServerError, ""},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.expected, recorder.Code)
+			assert.Equal(t, tc.challenge, recorder.Header().Get("WWW-Authenticate"))
+			if tc.token != "" {
+				assert.NotContains(t, logs.String(), tc.token)
+			}
+		})
+	}
+}
+
+func TestAuthenticateClaimsInContext(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	verifier := newJWTVerifier(t, &config.JWTConfig{JWKSFile: writeJWKSFile(t, key), JWKSRefreshInterval: time.Hour, Algorithms: []string{"RS256"}})
+	var logs bytes.Buffer
+	handler := makeAuthRouter(&logs, verifier)
+
+	req := httptest.NewRequest("GET", "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+key.sign(t, validClaims()))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "user", recorder.Body.String())
+}
+
+func TestAuthenticateWithoutVerifier(t *testing.T) {
+	var logs bytes.Buffer
+	handler := makeAuthRouter(&logs, nil /*verifier*/)
+
+	req := httptest.NewRequest("GET", "/private", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, `Bearer realm="starter-kit-http"`, recorder.Header().Get("WWW-Authenticate"))
+}
+
+// ========== Private Helpers ==========
+
+// Creates a router with public, authenticated, scoped and unregistered routes, wrapped in a request
+// logger and access log which write to the given writer
+func makeAuthRouter(logs io.Writer, verifier *auth.JWTVerifier) http.Handler {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := auth.ClaimsFromContext(r.Context())
+		w.Write([]byte(claims.Subject)) // nolint:errcheck
+	})
+
+	router := servertest.Router(route.Group{
+		Routes: []route.Route{
+			{Name: "public", Path: "/public", Handler: ok},
+			{Name: "private", Path: "/private", Handler: ok, Authenticated: true},
+			{Name: "admin", Path: "/admin", Handler: ok, Scopes: []string{"admin"}},
+			{Name: "whoami", Path: "/whoami", Handler: whoami, Authenticated: true},
+		},
+	}, auth.Authenticate(verifier, nil /*apiKeyVerifier*/))
+	// Routes added without route.Register have no authentication requirements
+	router.Path("/unregistered").Handler(ok)
+	return servertest.Handler(logs, router)
+}
+
+func signHS256(t *testing.T, claims jwt.MapClaims) string {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	return signed
+}
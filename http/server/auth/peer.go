@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// PeerIdentity ...
+// The identity of a client which presented a verified certificate during a mutual TLS handshake
+type PeerIdentity struct {
+	Subject        string
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []string
+	URIs           []string
+	// The SPIFFE ID of the peer (i.e. the first spiffe:// URI SAN), if any
+	// See https://github.com/spiffe/spiffe/blob/main/standards/X509-SVID.md
+	SPIFFEID string
+}
+
+type peerIdentityKey struct{}
+
+// NewPeerIdentity ...
+// Creates a PeerIdentity from a verified client certificate
+func NewPeerIdentity(cert *x509.Certificate) *PeerIdentity {
+	identity := &PeerIdentity{
+		Subject:        cert.Subject.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+	}
+	for _, ip := range cert.IPAddresses {
+		identity.IPAddresses = append(identity.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+		if identity.SPIFFEID == "" && uri.Scheme == "spiffe" {
+			identity.SPIFFEID = uri.String()
+		}
+	}
+
+	return identity
+}
+
+// SANs ...
+// Returns all subject alternative names of the peer
+func (p *PeerIdentity) SANs() []string {
+	sans := make([]string, 0, len(p.DNSNames)+len(p.EmailAddresses)+len(p.IPAddresses)+len(p.URIs))
+	sans = append(sans, p.DNSNames...)
+	sans = append(sans, p.EmailAddresses...)
+	sans = append(sans, p.IPAddresses...)
+	sans = append(sans, p.URIs...)
+	return sans
+}
+
+// PeerIdentityFromContext ...
+// Returns the verified peer identity stored in the given context, if any
+func PeerIdentityFromContext(ctx context.Context) (*PeerIdentity, bool) {
+	identity, ok := ctx.Value(peerIdentityKey{}).(*PeerIdentity)
+	return identity, ok
+}
+
+// WithPeerIdentity ...
+// Returns a copy of the given context which holds the given peer identity
+func WithPeerIdentity(ctx context.Context, identity *PeerIdentity) context.Context {
+	return context.WithValue(ctx, peerIdentityKey{}, identity)
+}
+
+// PeerIdentityHandler ...
+// Middleware which stores the identity of a verified client certificate in the
+// request context and adds it to the request logger. Requests without a verified
+// client certificate are passed through untouched.
+//
+// Note that this must be registered after hlog.NewHandler
+func PeerIdentityHandler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			identity := NewPeerIdentity(r.TLS.VerifiedChains[0][0])
+			log := zerolog.Ctx(r.Context())
+			log.UpdateContext(func(c zerolog.Context) zerolog.Context {
+				c = c.Str("peer_subject", identity.Subject).Strs("peer_sans", identity.SANs())
+				if identity.SPIFFEID != "" {
+					c = c.Str("peer_spiffe_id", identity.SPIFFEID)
+				}
+				return c
+			})
+
+			next.ServeHTTP(w, r.WithContext(WithPeerIdentity(r.Context(), identity)))
+		})
+	}
+}
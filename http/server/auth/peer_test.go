@@ -0,0 +1,54 @@
+package auth_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/spals/starter-kit/http/server/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPeerIdentity(t *testing.T) {
+	assert := assert.New(t)
+	spiffeID, _ := url.Parse("spiffe://example.org/ns/default/sa/client")
+	cert := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client", Organization: []string{"spals"}},
+		DNSNames:    []string{"client.example.org"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:        []*url.URL{spiffeID},
+	}
+
+	identity := auth.NewPeerIdentity(cert)
+	assert.Equal("CN=client,O=spals", identity.Subject)
+	assert.Equal("spiffe://example.org/ns/default/sa/client", identity.SPIFFEID)
+	assert.Equal([]string{"client.example.org", "10.0.0.1", "spiffe://example.org/ns/default/sa/client"}, identity.SANs())
+}
+
+func TestPeerIdentityHandler(t *testing.T) {
+	assert := assert.New(t)
+	var identity *auth.PeerIdentity
+	var found bool
+	handler := auth.PeerIdentityHandler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, found = auth.PeerIdentityFromContext(r.Context())
+	}))
+
+	// No TLS connection
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.False(found)
+
+	// Verified client certificate
+	req := httptest.NewRequest("GET", "/", nil)
+	req.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "client"}}}},
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if assert.True(found) {
+		assert.Equal("CN=client", identity.Subject)
+	}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/hlog"
+	"github.com/spals/starter-kit/http/server/problem"
+)
+
+// StaticTokenHandler ...
+// Middleware which requires a bearer token matching the given token in the
+// Authorization header. Requests without a matching token are rejected with a 401
+// and a WWW-Authenticate challenge for the given realm. The token is never logged.
+func StaticTokenHandler(realm string, token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented, ok := bearerToken(r)
+			if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				hlog.FromRequest(r).Warn().Bool("token_presented", ok).Msg("Rejected request with invalid bearer token")
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", realm))
+				problem.Write(w, r, problem.New(http.StatusUnauthorized, "A valid bearer token is required"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+// Returns the bearer token in the Authorization header, if any
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
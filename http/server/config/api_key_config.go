@@ -0,0 +1,30 @@
+package config
+
+import "time"
+
+// APIKeyConfig ...
+// Configuration used to authenticate requests with API keys. API key authentication
+// is enabled when a key file is configured.
+//
+// The key file is a JSON document which maps key IDs to hashed secrets, e.g.
+//
+//	{"keys": [{"id": "ci", "hash": "$2a$10$...", "principal": "ci-pipeline", "scopes": ["read"]}]}
+//
+// Hashes may be bcrypt or argon2id (PHC string format). Callers present keys in the
+// form <id>.<secret> in one of the configured headers.
+//
+// Note: All env variables are prefixed with API_KEY_ (see server_config.go)
+type APIKeyConfig struct {
+	// The file containing hashed API keys
+	File string `env:"FILE"`
+	// The request headers which may carry an API key, in order of precedence
+	Headers []string `env:"HEADERS,default=X-API-Key"`
+	// The interval at which the key file is checked for changes
+	ReloadInterval time.Duration `env:"RELOAD_INTERVAL,default=10s"`
+}
+
+// Enabled ...
+// Returns true if requests may be authenticated with API keys
+func (c *APIKeyConfig) Enabled() bool {
+	return c != nil && c.File != ""
+}
@@ -0,0 +1,17 @@
+package config
+
+// CompressionConfig ...
+// Configuration used to compress responses. The encoding is negotiated with each
+// client via the Accept-Encoding header.
+//
+// Note: All env variables are prefixed with COMPRESSION_ (see server_config.go)
+type CompressionConfig struct {
+	Enabled bool `env:"ENABLED,default=true"`
+	// Supported encodings in order of server preference (zstd|br|gzip). The server preference
+	// is used when a client accepts multiple encodings with equal weight.
+	Encodings []string `env:"ENCODINGS,default=zstd,br,gzip"`
+	// Responses smaller than this number of bytes are not compressed
+	MinSize int `env:"MIN_SIZE,default=1024"`
+	// Content types which are compressed. A type ending in /* matches all subtypes (e.g. text/*).
+	ContentTypes []string `env:"CONTENT_TYPES,default=application/json,application/problem+json,application/javascript,application/xml,image/svg+xml,text/*"`
+}
@@ -0,0 +1,31 @@
+package config
+
+import "time"
+
+// CORSConfig ...
+// Configuration used to serve cross-origin requests from browser clients. CORS is
+// enabled when at least one allowed origin is configured.
+//
+// Note: All env variables are prefixed with CORS_ (see server_config.go)
+type CORSConfig struct {
+	// Origins which may make cross-origin requests. Each origin is either an exact
+	// origin (e.g. https://app.example.com), a wildcard pattern (e.g. https://*.example.com)
+	// or * to allow all origins. Note that * may not be used alongside AllowCredentials.
+	AllowedOrigins []string `env:"ALLOWED_ORIGINS"`
+	// Methods which may be used in cross-origin requests
+	AllowedMethods []string `env:"ALLOWED_METHODS,default=GET,HEAD,POST,PUT,PATCH,DELETE"`
+	// Request headers which may be used in cross-origin requests, or * to allow all headers
+	AllowedHeaders []string `env:"ALLOWED_HEADERS,default=Accept,Authorization,Content-Type,Request-Id"`
+	// Response headers which browser clients may read
+	ExposedHeaders []string `env:"EXPOSED_HEADERS,default=Request-Id"`
+	// Allow cookies and authorization headers in cross-origin requests
+	AllowCredentials bool `env:"ALLOW_CREDENTIALS,default=false"`
+	// How long browser clients may cache preflight responses. A value of 0 omits the Access-Control-Max-Age header.
+	MaxAge time.Duration `env:"MAX_AGE,default=0s"`
+}
+
+// Enabled ...
+// Returns true if cross-origin requests should be served
+func (c *CORSConfig) Enabled() bool {
+	return c != nil && len(c.AllowedOrigins) > 0
+}
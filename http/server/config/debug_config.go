@@ -0,0 +1,15 @@
+package config
+
+// DebugConfig ...
+// Configuration used to serve runtime diagnostics (pprof, expvar and goroutine dumps)
+// under /debug/. These are always enabled in Dev mode. Otherwise, they must be
+// explicitly enabled and are only served by the admin server or behind an auth token.
+//
+// Note: All env variables are prefixed with DEBUG_ (see server_config.go)
+type DebugConfig struct {
+	// Serve debug endpoints when not in Dev mode
+	Enabled bool `env:"ENABLED,default=false"`
+	// An optional bearer token required to access debug endpoints. This is required
+	// when not in Dev mode and the admin server is disabled.
+	AuthToken string `env:"AUTH_TOKEN" json:"-" secret:"true"`
+}
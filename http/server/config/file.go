@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sethvargo/go-envconfig"
+	"go.yaml.in/yaml/v3"
+)
+
+// The env variable (without the HTTP_SERVER_ prefix) which names the configuration file
+const configFileKey = "CONFIG_FILE"
+
+// ========== Private Helpers ==========
+
+// Returns a lookuper of the values in the given configuration file, keyed by env
+// variable, or an empty lookuper if no file is given.
+//
+// The file is YAML, JSON or TOML (according to its extension) and mirrors the structure
+// of HTTPServerConfig. Keys match either the field name or the env variable of a field
+// regardless of case, dashes and underscores, so that the following are equivalent:
+//
+//	LivenessConfig:
+//	  MaxGoRoutines: 200
+//
+//	liveness:
+//	  max_go_routines: 200
+//
+// Lists are joined with commas and maps (e.g. RateLimitConfig.RouteRates) are joined as key:value
+// pairs, in the same way as env variables. Unknown keys are errors.
+func fileLookuper(file string) (envconfig.Lookuper, error) {
+	if file == "" {
+		return envconfig.MapLookuper(nil), nil
+	}
+
+	values, err := readConfigFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", file, err)
+	}
+	envValues := make(map[string]string)
+	if err := flattenConfig(reflect.TypeOf(HTTPServerConfig{}), values, "" /*path*/, "" /*prefix*/, envValues); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	return envconfig.MapLookuper(envValues), nil
+}
+
+// Reads the given configuration file into a generic map
+func readConfigFile(file string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		err = fmt.Errorf("unsupported extension %q (expected .yaml, .yml, .json or .toml)", ext)
+	}
+	return values, err
+}
+
+// Flattens the given values of the given configuration struct type into env values,
+// returning an error for each unknown key
+func flattenConfig(t reflect.Type, values map[string]interface{}, path string, prefix string, envValues map[string]string) error {
+	fields := fieldsByName(t)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		value := values[name]
+		fieldPath := joinPath(path, name)
+		field, ok := fields[normalizeKey(name)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown key %s", fieldPath))
+			continue
+		}
+		key, fieldPrefix, _ := envTag(field)
+		if prefix == "" && key == configFileKey {
+			errs = append(errs, fmt.Errorf("key %s may only be set by environment", fieldPath))
+			continue
+		}
+		if value == nil {
+			continue
+		}
+
+		if isStructType(field.Type) {
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				errs = append(errs, fmt.Errorf("key %s must be a table of values", fieldPath))
+				continue
+			}
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if err := flattenConfig(fieldType, nested, fieldPath, prefix+fieldPrefix, envValues); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		envValues[prefix+key] = envValue(value)
+	}
+	return errors.Join(errs...)
+}
+
+// Returns the configurable fields of the given struct type, keyed by each of their
+// normalized names (i.e. the field name and the env variable or prefix)
+func fieldsByName(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, prefix, ok := envTag(field)
+		if !ok {
+			continue
+		}
+		fields[normalizeKey(field.Name)] = field
+		if isStructType(field.Type) {
+			fields[normalizeKey(prefix)] = field
+		} else {
+			fields[normalizeKey(key)] = field
+		}
+	}
+	delete(fields, "")
+	return fields
+}
+
+// Normalizes the given key so that field names and env variables match (e.g.
+// MaxGoRoutines, max_go_routines and MAX_GO_ROUTINES all become maxgoroutines)
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+// Formats the given file value as an env value
+func envValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		elems := make([]string, 0, len(v))
+		for _, elem := range v {
+			elems = append(elems, envValue(elem))
+		}
+		return strings.Join(elems, ",")
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(v))
+		for k, elem := range v {
+			pairs = append(pairs, k+":"+envValue(elem))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,112 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestConfigFile(t *testing.T) {
+	testCases := map[string]struct {
+		name    string
+		content string
+	}{
+		"yaml": {"config.yaml", `
+log_level: debug
+port: 8080
+rate_limit:
+  route_rates:
+    /config: 2
+cors:
+  allowed_origins: [https://a.example.com, https://b.example.com]
+LivenessConfig:
+  MaxGoRoutines: 200
+`},
+		"json": {"config.json", `{
+  "LOG_LEVEL": "debug",
+  "Port": 8080,
+  "RateLimitConfig": {"RouteRates": {"/config": 2}},
+  "CORSConfig": {"AllowedOrigins": ["https://a.example.com", "https://b.example.com"]},
+  "liveness": {"max-go-routines": 200}
+}`},
+		"toml": {"config.toml", `
+log_level = "debug"
+port = 8080
+
+[rate_limit.route_rates]
+"/config" = 2
+
+[cors]
+allowed_origins = ["https://a.example.com", "https://b.example.com"]
+
+[liveness]
+max_go_routines = 200
+`},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			file := writeConfigFile(t, tc.name, tc.content)
+			c, err := config.NewHTTPServerConfig(envconfig.MapLookuper(map[string]string{
+				"CONFIG_FILE": file,
+				"PORT":        "9090",
+			}))
+			if !assert.NoError(err) {
+				return
+			}
+
+			// Env variables take precedence over the file
+			assert.Equal(9090, c.Port)
+			assert.Equal("debug", c.LogLevel)
+			assert.Equal(map[string]float64{"/config": 2}, c.RateLimitConfig.RouteRates)
+			assert.Equal([]string{"https://a.example.com", "https://b.example.com"}, c.CORSConfig.AllowedOrigins)
+			assert.Equal(200, c.LivenessConfig.MaxGoRoutines)
+			assert.Equal(time.Second, c.ShutdownTimeout)
+
+			assert.Equal(config.SourceEnv, c.Sources["ConfigFile"])
+			assert.Equal(config.SourceEnv, c.Sources["Port"])
+			assert.Equal(config.SourceFile, c.Sources["LogLevel"])
+			assert.Equal(config.SourceFile, c.Sources["LivenessConfig.MaxGoRoutines"])
+			assert.Equal(config.SourceDefault, c.Sources["ShutdownTimeout"])
+		})
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	testCases := map[string]struct {
+		name          string
+		content       string
+		expectedError string
+	}{
+		"unknown key":        {"config.yaml", "log_level: info\nprot: 8080\n", "unknown key prot"},
+		"unknown nested key": {"config.yaml", "log_level: info\nliveness:\n  max_threads: 1\n", "unknown key liveness.max_threads"},
+		"not a table":        {"config.json", `{"LOG_LEVEL": "info", "liveness": 200}`, "key liveness must be a table of values"},
+		"config file key":    {"config.toml", "config_file = \"other.toml\"\n", "key config_file may only be set by environment"},
+		"malformed":          {"config.json", `{"LOG_LEVEL": `, "error reading config file"},
+		"unknown extension":  {"config.ini", "LOG_LEVEL=info\n", "unsupported extension"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			file := writeConfigFile(t, tc.name, tc.content)
+			_, err := config.NewHTTPServerConfig(envconfig.MapLookuper(map[string]string{"CONFIG_FILE": file}))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
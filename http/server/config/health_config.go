@@ -6,6 +6,8 @@ package config
 // Note: All env variables are prefixed with LIVENESS_ (see httpServerConfig.go)
 type LivenessConfig struct {
 	MaxGoRoutines int `env:"MAX_GO_ROUTINES,default=100"`
+	// The number of recovered handler panics above which the server is no longer live. A value of 0 disables the check.
+	MaxPanics int `env:"MAX_PANICS,default=0"`
 }
 
 // ReadinessConfig ...
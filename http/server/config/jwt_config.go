@@ -0,0 +1,33 @@
+package config
+
+import "time"
+
+// JWTConfig ...
+// Configuration used to authenticate requests with JWT bearer tokens. JWT authentication
+// is enabled when either a JWKS file or a JWKS URL (but not both) is configured.
+//
+// Note: All env variables are prefixed with JWT_ (see server_config.go)
+type JWTConfig struct {
+	// A local JSON Web Key Set file containing the keys used to verify tokens
+	JWKSFile string `env:"JWKS_FILE"`
+	// A URL serving the JSON Web Key Set used to verify tokens (e.g. https://issuer.example.com/.well-known/jwks.json)
+	JWKSURL string `env:"JWKS_URL"`
+	// The minimum time between reloads of the JSON Web Key Set
+	JWKSRefreshInterval time.Duration `env:"JWKS_REFRESH_INTERVAL,default=5m"`
+	// The required iss claim. If empty, then the issuer is not checked.
+	Issuer string `env:"ISSUER"`
+	// The required aud claim. If empty, then the audience is not checked.
+	Audience string `env:"AUDIENCE"`
+	// The tolerance applied when checking the exp, nbf and iat claims
+	ClockSkew time.Duration `env:"CLOCK_SKEW,default=30s"`
+	// The accepted signing algorithms
+	Algorithms []string `env:"ALGORITHMS,default=RS256,RS384,RS512,PS256,PS384,PS512,ES256,ES384,ES512,EdDSA"`
+	// The realm sent in WWW-Authenticate challenges
+	Realm string `env:"REALM,default=starter-kit-http"`
+}
+
+// Enabled ...
+// Returns true if requests may be authenticated with JWT bearer tokens
+func (c *JWTConfig) Enabled() bool {
+	return c != nil && (c.JWKSFile != "" || c.JWKSURL != "")
+}
@@ -0,0 +1,35 @@
+package config
+
+// RateLimitConfig ...
+// Configuration used to rate limit clients of HTTPServer with token buckets. A client
+// may make Burst requests at once and then Rate requests per second thereafter.
+//
+// Note: All env variables are prefixed with RATE_LIMIT_ (see server_config.go)
+type RateLimitConfig struct {
+	// The number of requests per second allowed for each client across all routes. A value of 0 disables the global limit.
+	Rate float64 `env:"RATE,default=0"`
+	// The number of requests each client may make at once across all routes. A value of 0 uses the rate rounded up.
+	Burst int `env:"BURST,default=0"`
+	// Route specific requests per second for each client keyed by route path template (e.g. /login:0.5,/users/{id}:20).
+	// These apply in addition to the global limit.
+	RouteRates map[string]float64 `env:"ROUTE_RATES"`
+	// Route specific bursts keyed by route path template. If a route rate has no burst, then the rate rounded up is used.
+	RouteBursts map[string]int `env:"ROUTE_BURSTS"`
+
+	// How clients are identified (ip|api_key|header). When keyed by api_key, authenticated clients are identified
+	// by their verified API key ID or JWT subject. When keyed by header, clients are also limited by IP as the
+	// header cannot be verified. Clients without credentials or the header are identified by IP.
+	KeyBy string `env:"KEY_BY,default=ip"`
+	// The header which identifies the client when keyed by header (e.g. X-Client-ID)
+	KeyHeader string `env:"KEY_HEADER"`
+	// Route path templates which are never limited (e.g. health checks and metrics scrapes)
+	ExemptRoutes []string `env:"EXEMPT_ROUTES,default=/live,/ready,/metrics"`
+	// The maximum number of client buckets held in memory. The least recently used buckets are evicted first.
+	MaxKeys int `env:"MAX_KEYS,default=10000"`
+}
+
+// Enabled ...
+// Returns true if any rate limit is configured
+func (c *RateLimitConfig) Enabled() bool {
+	return c != nil && (c.Rate > 0 || len(c.RouteRates) > 0)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	nativelog "log"
 	"os"
 	"time"
 
@@ -22,55 +21,126 @@ type HTTPServerConfig struct {
 	Port            int           `env:"PORT,default=0"`
 	LogLevel        string        `env:"LOG_LEVEL"`
 	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT,default=1s"`
+	// An optional port for a separate server which serves operational endpoints (e.g. /config, /live, /ready).
+	// A value of -1 disables the admin server and a value of 0 assigns a random port.
+	AdminPort int `env:"ADMIN_PORT,default=-1"`
+	// An optional listen address which overrides Port (e.g. tcp://127.0.0.1:8080, unix:///run/app.sock or systemd:)
+	Listen string `env:"LISTEN"`
+	// The protocol mode used to serve requests (http1|h2c|h3). Note that h3 requires TLS.
+	Protocol string `env:"PROTOCOL,default=http1"`
+	// Time to wait after failing readiness checks and before shutting down, in order to allow
+	// load balancers to stop routing traffic to the server
+	DrainDelay time.Duration `env:"DRAIN_DELAY,default=0s"`
+	// Time to wait for a new process to become ready during a binary upgrade (see main.go)
+	UpgradeTimeout time.Duration `env:"UPGRADE_TIMEOUT,default=30s"`
+	// An optional YAML, JSON or TOML file of configuration values. Values are layered with
+	// defaults first, then values from the file, then values from env variables.
+	ConfigFile string `env:"CONFIG_FILE"`
 
-	LivenessConfig  *LivenessConfig  `env:",prefix=LIVENESS_"`
-	ReadinessConfig *ReadinessConfig `env:"prefix=READINESS_"`
+	// Server timeouts and limits
+	// See https://pkg.go.dev/net/http#Server
+	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT,default=10s"`
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT,default=30s"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT,default=30s"`
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT,default=120s"`
+	MaxHeaderBytes    int           `env:"MAX_HEADER_BYTES,default=1048576"`
+	// The default deadline applied to each request, which routes may override (see route.Route).
+	// A value of 0 disables the default deadline.
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT,default=0s"`
+	// The default maximum request body size in bytes, which routes may override (see route.Route).
+	// A value of 0 removes the default limit.
+	MaxBodyBytes int64 `env:"MAX_BODY_BYTES,default=1048576"`
+
+	LivenessConfig    *LivenessConfig    `env:",prefix=LIVENESS_"`
+	ReadinessConfig   *ReadinessConfig   `env:",prefix=READINESS_"`
+	TLSConfig         *TLSConfig         `env:",prefix=TLS_"`
+	TracingConfig     *TracingConfig     `env:",prefix=TRACING_"`
+	DebugConfig       *DebugConfig       `env:",prefix=DEBUG_"`
+	CORSConfig        *CORSConfig        `env:",prefix=CORS_"`
+	CompressionConfig *CompressionConfig `env:",prefix=COMPRESSION_"`
+	RateLimitConfig   *RateLimitConfig   `env:",prefix=RATE_LIMIT_"`
+	JWTConfig         *JWTConfig         `env:",prefix=JWT_"`
+	APIKeyConfig      *APIKeyConfig      `env:",prefix=API_KEY_"`
 
 	// Configured logger used for request logging
 	ReqLogger zerolog.Logger
+	// The source of each configurable value, keyed by field path (e.g. LivenessConfig.MaxGoRoutines)
+	Sources map[string]Source
 }
 
 // NewHTTPServerConfig ...
-func NewHTTPServerConfig(l envconfig.Lookuper) *HTTPServerConfig {
+// Parses the configuration from the given lookuper (and the configuration file which it
+// names, if any) and validates it (see Validate). Every validation violation is returned
+// as a single error.
+func NewHTTPServerConfig(l envconfig.Lookuper) (*HTTPServerConfig, error) {
 	ctx := context.Background()
 	var config HTTPServerConfig
 
-	if err := envconfig.ProcessWith(ctx, &config, l); err != nil {
-		nativelog.Fatalf("HTTPServerConfig parse failure: %s", err)
-		os.Exit(1)
+	configFile, _ := l.Lookup(configFileKey)
+	fileValues, err := fileLookuper(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("HTTPServerConfig file failure: %w", err)
 	}
 
-	// Configure logging as early as possible (i.e. as soon as we have a parsed configuration)
-	config.configureLogging()
-	log.Debug().Interface("config", config).Msg("HTTPServerConfig parsed")
-	return &config
+	// Env variables take precedence over file values, which take precedence over defaults
+	if err := envconfig.ProcessWith(ctx, &config, envconfig.MultiLookuper(l, fileValues)); err != nil {
+		return nil, fmt.Errorf("HTTPServerConfig parse failure: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("HTTPServerConfig validation failure:\n%w", err)
+	}
+
+	config.Sources = sourcesOf(&config, l, fileValues)
+
+	// Configure logging as early as possible (i.e. as soon as we have a valid configuration)
+	if err := config.configureLogging(); err != nil {
+		return nil, err
+	}
+	log.Debug().Interface("config", config.View()).Msg("HTTPServerConfig parsed")
+	return &config, nil
+}
+
+// AdminEnabled ...
+// Returns true if operational endpoints should be served by a separate admin server
+func (c *HTTPServerConfig) AdminEnabled() bool {
+	return c.AdminPort >= 0
+}
+
+// DebugEnabled ...
+// Returns true if debug endpoints should be served
+func (c *HTTPServerConfig) DebugEnabled() bool {
+	return c.Dev || c.DebugConfig.Enabled
 }
 
 // ToJSONString ...
+// Returns the configuration view as JSON (see View)
 func (c *HTTPServerConfig) ToJSONString(prettyPrint bool) string {
 	if prettyPrint {
-		json, _ := json.MarshalIndent(c, "", "  ")
+		json, _ := json.MarshalIndent(c.View(), "", "  ")
 		return string(json)
 	}
 
-	json, _ := json.Marshal(c)
+	json, _ := json.Marshal(c.View())
 	return string(json)
 }
 
 // ========== Private Helpers ==========
 
-func (c *HTTPServerConfig) configureLogging() {
+func (c *HTTPServerConfig) configureLogging() error {
+	logger, err := c.newLogger()
+	if err != nil {
+		return err
+	}
 	// Set the default logger as the application logger
-	log.Logger = c.newLogger().With().Str("system", "starter-kit-http").Logger()
-	c.ReqLogger = c.newLogger().With().Str("system", "http-request").Logger()
+	log.Logger = logger.With().Str("system", "starter-kit-http").Logger()
+	c.ReqLogger = logger.With().Str("system", "http-request").Logger()
+	return nil
 }
 
-func (c *HTTPServerConfig) newLogger() zerolog.Logger {
-	logLevel, err := zerolog.ParseLevel(c.LogLevel)
+func (c *HTTPServerConfig) newLogger() (zerolog.Logger, error) {
+	logLevel, err := parseLogLevel(c.LogLevel)
 	if err != nil {
-		nativelog.Fatalf("Error while parsing log level: %s. Available log levels are (trace|debug|info|warn|error|fatal|panic)", err)
-	} else if logLevel == zerolog.NoLevel {
-		nativelog.Fatalf("No log level configured. Please specify a log level (trace|debug|info|warn|error|fatal|panic)")
+		return zerolog.Nop(), err
 	}
 	zerolog.SetGlobalLevel(logLevel)
 
@@ -80,9 +150,9 @@ func (c *HTTPServerConfig) newLogger() zerolog.Logger {
 			return fmt.Sprintf("[%s]:", i)
 		}
 
-		return zerolog.New(output).With().Timestamp().Caller().Logger()
+		return zerolog.New(output).With().Timestamp().Caller().Logger(), nil
 	} else {
 		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-		return zerolog.New(os.Stderr).With().Timestamp().Logger()
+		return zerolog.New(os.Stderr).With().Timestamp().Logger(), nil
 	}
 }
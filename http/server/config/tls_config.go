@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// TLSConfig ...
+// Configuration used to terminate TLS in HTTPServer. TLS is enabled when both
+// a certificate file and a key file are configured.
+//
+// Note: All env variables are prefixed with TLS_ (see server_config.go)
+type TLSConfig struct {
+	CertFile string `env:"CERT_FILE"`
+	KeyFile  string `env:"KEY_FILE"`
+	// The minimum TLS version accepted by the server (1.0|1.1|1.2|1.3)
+	MinVersion string `env:"MIN_VERSION,default=1.2"`
+	// An optional list of cipher suite names (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256).
+	// If empty, then the Go defaults are used. Note that TLS 1.3 cipher suites are not configurable.
+	CipherSuites []string `env:"CIPHER_SUITES"`
+	// The minimum time between checks for changes to the certificate and key files
+	ReloadInterval time.Duration `env:"RELOAD_INTERVAL,default=10s"`
+
+	// The client certificate authentication mode:
+	//  none    - client certificates are not requested
+	//  request - client certificates are requested and verified against the client CA bundle if presented
+	//  require - client certificates are required, but not verified
+	//  verify  - client certificates are required and verified against the client CA bundle
+	ClientAuth string `env:"CLIENT_AUTH,default=none"`
+	// A PEM encoded CA bundle used to verify client certificates
+	ClientCAFile string `env:"CLIENT_CA_FILE"`
+}
+
+// Enabled ...
+// Returns true if TLS should be terminated by HTTPServer
+func (c *TLSConfig) Enabled() bool {
+	return c != nil && (c.CertFile != "" || c.KeyFile != "")
+}
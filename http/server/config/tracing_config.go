@@ -0,0 +1,24 @@
+package config
+
+// TracingConfig ...
+// Configuration used to export OpenTelemetry traces from HTTPServer. Incoming W3C
+// trace context is always propagated, even if no exporter is configured.
+//
+// Note: All env variables are prefixed with TRACING_ (see server_config.go)
+type TracingConfig struct {
+	// The exporter to which spans are sent (none|stdout|otlp)
+	Exporter string `env:"EXPORTER,default=none"`
+	// The OTLP/HTTP collector endpoint used by the otlp exporter
+	OTLPEndpoint string `env:"OTLP_ENDPOINT,default=http://localhost:4318"`
+	// The service name attached to all exported spans
+	ServiceName string `env:"SERVICE_NAME,default=starter-kit-http"`
+	// The ratio of new traces which are sampled (0.0 to 1.0). Traces started by a caller
+	// follow the caller's sampling decision.
+	SampleRatio float64 `env:"SAMPLE_RATIO,default=1.0"`
+}
+
+// Enabled ...
+// Returns true if spans should be exported
+func (c *TracingConfig) Enabled() bool {
+	return c != nil && c.Exporter != "" && c.Exporter != "none"
+}
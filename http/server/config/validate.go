@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Validator ...
+// A validation rule for HTTPServerConfig, which returns an error describing each violation
+// of the rule (e.g. via errors.Join) or nil if the configuration is valid
+type Validator func(c *HTTPServerConfig) error
+
+// The built-in validation rules
+var builtinValidators = []Validator{validatePorts, validateDurations, validateLogLevel, validateLiveness, validateCORS, validateRateLimit, validateJWT}
+
+// The custom validation rules, in registration order
+var (
+	validatorsMu     sync.Mutex
+	customValidators []customValidator
+	nextValidatorID  int
+)
+
+// A custom validation rule along with the ID used to unregister it
+type customValidator struct {
+	id   int
+	rule Validator
+}
+
+// RegisterValidator ...
+// Registers a custom validation rule, which is applied by NewHTTPServerConfig (and
+// Validate) after the built-in rules. Custom rules should be registered before the
+// server is initialized (e.g. in an init func). The returned func unregisters the rule
+// (e.g. in test cleanup).
+func RegisterValidator(v Validator) func() {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	nextValidatorID++
+	id := nextValidatorID
+	customValidators = append(customValidators, customValidator{id: id, rule: v})
+
+	return func() {
+		validatorsMu.Lock()
+		defer validatorsMu.Unlock()
+		for i, cv := range customValidators {
+			if cv.id == id {
+				customValidators = append(customValidators[:i:i], customValidators[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Validate ...
+// Applies all validation rules to the configuration and returns a single error which
+// joins every violation, or nil if the configuration is valid
+func (c *HTTPServerConfig) Validate() error {
+	rules := append([]Validator(nil), builtinValidators...)
+	validatorsMu.Lock()
+	for _, cv := range customValidators {
+		rules = append(rules, cv.rule)
+	}
+	validatorsMu.Unlock()
+
+	var errs []error
+	for _, rule := range rules {
+		if err := rule(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ========== Private Helpers ==========
+
+// Ports must be valid TCP ports (or 0 for a random port). The admin port may also be -1,
+// which disables the admin server.
+func validatePorts(c *HTTPServerConfig) error {
+	var errs []error
+	if c.Port < 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("Port must be between 0 and 65535 (got %d)", c.Port))
+	}
+	if c.AdminPort < -1 || c.AdminPort > 65535 {
+		errs = append(errs, fmt.Errorf("AdminPort must be between -1 and 65535 (got %d)", c.AdminPort))
+	}
+	return errors.Join(errs...)
+}
+
+// Durations must not be negative, and durations which a zero value would break must be positive
+func validateDurations(c *HTTPServerConfig) error {
+	var errs []error
+	durationType := reflect.TypeOf(time.Duration(0))
+	walkFields(reflect.ValueOf(c).Elem(), "" /*path*/, "" /*prefix*/, func(f configField) {
+		if f.value.Type() != durationType {
+			return
+		}
+		if d := time.Duration(f.value.Int()); d < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative (got %s)", f.path, d))
+		}
+	})
+
+	if c.ShutdownTimeout == 0 {
+		errs = append(errs, errors.New("ShutdownTimeout must be positive"))
+	}
+	if c.UpgradeTimeout == 0 {
+		errs = append(errs, errors.New("UpgradeTimeout must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// The log level must be a known zerolog level
+func validateLogLevel(c *HTTPServerConfig) error {
+	_, err := parseLogLevel(c.LogLevel)
+	return err
+}
+
+// Liveness limits must be positive
+func validateLiveness(c *HTTPServerConfig) error {
+	if c.LivenessConfig != nil && c.LivenessConfig.MaxGoRoutines <= 0 {
+		return fmt.Errorf("LivenessConfig.MaxGoRoutines must be positive (got %d)", c.LivenessConfig.MaxGoRoutines)
+	}
+	return nil
+}
+
+// Credentials may not be allowed for every origin, as this would allow any website to
+// make credentialed cross-origin requests (which the CORS specification forbids)
+func validateCORS(c *HTTPServerConfig) error {
+	if c.CORSConfig == nil || !c.CORSConfig.AllowCredentials {
+		return nil
+	}
+	for _, origin := range c.CORSConfig.AllowedOrigins {
+		if origin == "*" {
+			return errors.New("CORSConfig.AllowedOrigins may not contain * when CORSConfig.AllowCredentials is enabled")
+		}
+	}
+	return nil
+}
+
+// Rate limited clients must be identified by a supported key, and a header key requires a header name
+func validateRateLimit(c *HTTPServerConfig) error {
+	if c.RateLimitConfig == nil {
+		return nil
+	}
+	switch c.RateLimitConfig.KeyBy {
+	case "ip", "api_key":
+		return nil
+	case "header":
+		if c.RateLimitConfig.KeyHeader == "" {
+			return errors.New("RateLimitConfig.KeyHeader must be configured when RateLimitConfig.KeyBy is header")
+		}
+		return nil
+	default:
+		return fmt.Errorf("RateLimitConfig.KeyBy must be one of (ip|api_key|header) (got %q)", c.RateLimitConfig.KeyBy)
+	}
+}
+
+// Keys must come from a single JSON Web Key Set, so a file and a URL may not both be configured
+func validateJWT(c *HTTPServerConfig) error {
+	if c.JWTConfig != nil && c.JWTConfig.JWKSFile != "" && c.JWTConfig.JWKSURL != "" {
+		return errors.New("JWTConfig.JWKSFile and JWTConfig.JWKSURL may not both be configured")
+	}
+	return nil
+}
+
+// Parses the given log level, which must be set
+func parseLogLevel(level string) (zerolog.Level, error) {
+	logLevel, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return logLevel, fmt.Errorf("LogLevel is unknown: %w. Available log levels are (trace|debug|info|warn|error|fatal|panic)", err)
+	} else if logLevel == zerolog.NoLevel {
+		return logLevel, errors.New("LogLevel is not configured. Please specify a log level (trace|debug|info|warn|error|fatal|panic)")
+	}
+	return logLevel, nil
+}
@@ -0,0 +1,106 @@
+package config_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := map[string]struct {
+		configMap      map[string]string
+		expectedErrors []string
+	}{
+		"valid":                 {map[string]string{"LOG_LEVEL": "info", "PORT": "8080", "ADMIN_PORT": "-1"}, nil},
+		"no log level":          {map[string]string{}, []string{"LogLevel is not configured"}},
+		"unknown log level":     {map[string]string{"LOG_LEVEL": "loud"}, []string{"LogLevel is unknown"}},
+		"port out of range":     {map[string]string{"LOG_LEVEL": "info", "PORT": "70000"}, []string{"Port must be between 0 and 65535 (got 70000)"}},
+		"admin port negative":   {map[string]string{"LOG_LEVEL": "info", "ADMIN_PORT": "-2"}, []string{"AdminPort must be between -1 and 65535 (got -2)"}},
+		"negative duration":     {map[string]string{"LOG_LEVEL": "info", "CORS_MAX_AGE": "-1s"}, []string{"CORSConfig.MaxAge must not be negative (got -1s)"}},
+		"zero shutdown timeout": {map[string]string{"LOG_LEVEL": "info", "SHUTDOWN_TIMEOUT": "0s"}, []string{"ShutdownTimeout must be positive"}},
+		"zero max go routines":  {map[string]string{"LOG_LEVEL": "info", "LIVENESS_MAX_GO_ROUTINES": "0"}, []string{"LivenessConfig.MaxGoRoutines must be positive (got 0)"}},
+		"cors any origin with credentials": {
+			map[string]string{"LOG_LEVEL": "info", "CORS_ALLOWED_ORIGINS": "https://app.example.com,*", "CORS_ALLOW_CREDENTIALS": "true"},
+			[]string{"CORSConfig.AllowedOrigins may not contain * when CORSConfig.AllowCredentials is enabled"},
+		},
+		"unknown rate limit key": {
+			map[string]string{"LOG_LEVEL": "info", "RATE_LIMIT_KEY_BY": "cookie"},
+			[]string{`RateLimitConfig.KeyBy must be one of (ip|api_key|header) (got "cookie")`},
+		},
+		"rate limit header key without header": {
+			map[string]string{"LOG_LEVEL": "info", "RATE_LIMIT_KEY_BY": "header"},
+			[]string{"RateLimitConfig.KeyHeader must be configured when RateLimitConfig.KeyBy is header"},
+		},
+		"rate limit header key": {map[string]string{"LOG_LEVEL": "info", "RATE_LIMIT_KEY_BY": "header", "RATE_LIMIT_KEY_HEADER": "X-Client-ID"}, nil},
+		"jwks file and url": {
+			map[string]string{"LOG_LEVEL": "info", "JWT_JWKS_FILE": "jwks.json", "JWT_JWKS_URL": "https://issuer.example.com/.well-known/jwks.json"},
+			[]string{"JWTConfig.JWKSFile and JWTConfig.JWKSURL may not both be configured"},
+		},
+		"all violations": {
+			map[string]string{"PORT": "-1", "READ_TIMEOUT": "-5s", "LIVENESS_MAX_GO_ROUTINES": "-3"},
+			[]string{
+				"Port must be between 0 and 65535 (got -1)",
+				"ReadTimeout must not be negative (got -5s)",
+				"LogLevel is not configured",
+				"LivenessConfig.MaxGoRoutines must be positive (got -3)",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			c, err := config.NewHTTPServerConfig(envconfig.MapLookuper(tc.configMap))
+			if len(tc.expectedErrors) == 0 {
+				assert.NoError(err)
+				assert.NotNil(c)
+				return
+			}
+
+			assert.Nil(c)
+			if assert.Error(err) {
+				assert.Equal(len(tc.expectedErrors), strings.Count(err.Error(), "\n"))
+				for _, expectedError := range tc.expectedErrors {
+					assert.Contains(err.Error(), expectedError)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFailure(t *testing.T) {
+	_, err := config.NewHTTPServerConfig(envconfig.MapLookuper(map[string]string{"LOG_LEVEL": "info", "PORT": "eighty"}))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "HTTPServerConfig parse failure")
+	}
+}
+
+func TestRegisterValidator(t *testing.T) {
+	assert := assert.New(t)
+	errNotDev := errors.New("Dev must be enabled")
+	unregister := config.RegisterValidator(func(c *config.HTTPServerConfig) error {
+		if c.LogLevel == "trace" && !c.Dev {
+			return errNotDev
+		}
+		return nil
+	})
+	t.Cleanup(unregister)
+
+	_, err := config.NewHTTPServerConfig(envconfig.MapLookuper(map[string]string{"LOG_LEVEL": "trace", "PORT": "70000"}))
+	if assert.Error(err) {
+		assert.True(errors.Is(err, errNotDev))
+		assert.Contains(err.Error(), "Port must be between 0 and 65535")
+	}
+
+	_, err = config.NewHTTPServerConfig(envconfig.MapLookuper(map[string]string{"LOG_LEVEL": "trace", "DEV": "true"}))
+	assert.NoError(err)
+
+	// Unregistered rules no longer apply
+	unregister()
+	_, err = config.NewHTTPServerConfig(envconfig.MapLookuper(map[string]string{"LOG_LEVEL": "trace"}))
+	assert.NoError(err)
+}
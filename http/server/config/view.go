@@ -0,0 +1,183 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+)
+
+// Source ...
+// Where a configuration value came from
+type Source string
+
+const (
+	// The value is the default (either the default in its env tag or the zero value)
+	SourceDefault Source = "default"
+	// The value was read from the configuration file (see HTTPServerConfig.ConfigFile)
+	SourceFile Source = "file"
+	// The value was read from an environment variable
+	SourceEnv Source = "env"
+	// The value was overwritten by the server at runtime (e.g. a random port replaced by the bound port)
+	SourceRuntime Source = "runtime"
+)
+
+// The value shown in place of secret values
+const redacted = "[REDACTED]"
+
+// View ...
+// Returns a view of the configuration which is safe to expose (e.g. via the /config
+// endpoint), keyed by field name. Only configurable fields (i.e. those with an env tag)
+// are included, so internal fields such as ReqLogger are excluded. The values of fields
+// tagged with secret:"true" are redacted.
+func (c *HTTPServerConfig) View() map[string]interface{} {
+	return viewOf(reflect.ValueOf(c).Elem())
+}
+
+// SetRuntimeSource ...
+// Records that the field at the given path (e.g. AdminPort) was overwritten at runtime.
+// This must be called before the configuration is served (e.g. via the /config endpoint).
+func (c *HTTPServerConfig) SetRuntimeSource(path string) {
+	if c.Sources == nil {
+		c.Sources = make(map[string]Source)
+	}
+	c.Sources[path] = SourceRuntime
+}
+
+// ========== Private Helpers ==========
+
+// A configurable field, found by walking the env tags of a configuration struct
+type configField struct {
+	// The dotted path of the field (e.g. LivenessConfig.MaxGoRoutines)
+	path string
+	// The env variable of the field, including the prefixes of its parent structs (e.g. LIVENESS_MAX_GO_ROUTINES)
+	key string
+	// The value of the field (the zero value if a parent struct is nil)
+	value reflect.Value
+}
+
+// Calls the given function for each configurable leaf field of the given struct, in the
+// same way that envconfig processes fields
+func walkFields(v reflect.Value, path string, prefix string, fn func(configField)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, fieldPrefix, ok := envTag(field)
+		if !ok {
+			continue
+		}
+		fieldPath := joinPath(path, field.Name)
+
+		value := v.Field(i)
+		if isStructType(field.Type) {
+			structValue := value
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					structValue = reflect.New(field.Type.Elem()).Elem()
+				} else {
+					structValue = value.Elem()
+				}
+			}
+			walkFields(structValue, fieldPath, prefix+fieldPrefix, fn)
+			continue
+		}
+		fn(configField{path: fieldPath, key: prefix + key, value: value})
+	}
+}
+
+// Returns the env variable and prefix options of the env tag of the given field, or
+// false if the field is not configurable
+func envTag(field reflect.StructField) (string, string, bool) {
+	tag, ok := field.Tag.Lookup("env")
+	if !ok || field.PkgPath != "" {
+		return "", "", false
+	}
+	key, opts, _ := strings.Cut(tag, ",")
+	prefix := ""
+	for _, opt := range strings.Split(opts, ",") {
+		if p, ok := strings.CutPrefix(strings.TrimSpace(opt), "prefix="); ok {
+			prefix += p
+		}
+	}
+	return strings.TrimSpace(key), prefix, true
+}
+
+// Returns a view of the configurable fields of the given struct
+func viewOf(v reflect.Value) map[string]interface{} {
+	view := make(map[string]interface{})
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := field.Tag.Lookup("env"); !ok || field.PkgPath != "" {
+			continue
+		}
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			view[field.Name] = nil
+		} else if isStructType(field.Type) {
+			view[field.Name] = viewOf(reflect.Indirect(value))
+		} else if field.Tag.Get("secret") == "true" {
+			view[field.Name] = redactedValue(value)
+		} else {
+			view[field.Name] = viewValue(value)
+		}
+	}
+	return view
+}
+
+// Returns true if the given type is a struct or a pointer to a struct
+func isStructType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// Returns the given value in a readable form (e.g. durations as strings)
+func viewValue(v reflect.Value) interface{} {
+	durationType := reflect.TypeOf(time.Duration(0))
+	switch {
+	case v.Type() == durationType:
+		return v.Interface().(time.Duration).String()
+	case v.Kind() == reflect.Map && v.Type().Elem() == durationType:
+		durations := make(map[string]string, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			durations[iter.Key().String()] = iter.Value().Interface().(time.Duration).String()
+		}
+		return durations
+	default:
+		return v.Interface()
+	}
+}
+
+// Redacts the given secret value, unless it is unset
+func redactedValue(v reflect.Value) interface{} {
+	if v.IsZero() {
+		return viewValue(v)
+	}
+	return redacted
+}
+
+// Returns the source of each configurable field of the given config, keyed by field path
+func sourcesOf(c *HTTPServerConfig, env envconfig.Lookuper, file envconfig.Lookuper) map[string]Source {
+	sources := make(map[string]Source)
+	walkFields(reflect.ValueOf(c).Elem(), "" /*path*/, "" /*prefix*/, func(f configField) {
+		sources[f.path] = SourceDefault
+		if _, ok := env.Lookup(f.key); ok {
+			sources[f.path] = SourceEnv
+		} else if _, ok := file.Lookup(f.key); ok {
+			sources[f.path] = SourceFile
+		}
+	})
+	return sources
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
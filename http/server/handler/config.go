@@ -1,26 +1,141 @@
 package handler
 
 import (
+	"encoding/json"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/problem"
+	"github.com/spals/starter-kit/http/server/route"
+	"go.yaml.in/yaml/v3"
+)
+
+// Content types produced by HTTPServerConfigHandler
+const (
+	jsonContentType = "application/json; charset=utf-8"
+	yamlContentType = "application/yaml; charset=utf-8"
 )
 
 // HTTPServerConfigHandler ...
-// HTTP handler which returns HTTP server configuration
+// HTTP handler which returns HTTP server configuration, along with the source of each
+// value (see config.Source). Secret values are redacted and internal fields are
+// excluded (see config.HTTPServerConfig.View).
+//
+// The response is JSON or YAML, as negotiated from the Accept header. JSON responses
+// are indented when the pretty=true query parameter is given.
 type HTTPServerConfigHandler struct {
 	config *config.HTTPServerConfig
 }
 
+// The response body of HTTPServerConfigHandler
+type configResponse struct {
+	Config  map[string]interface{}   `json:"config" yaml:"config"`
+	Sources map[string]config.Source `json:"sources" yaml:"sources"`
+}
+
 // NewHTTPServerConfigHandler ...
 func NewHTTPServerConfigHandler(config *config.HTTPServerConfig) *HTTPServerConfigHandler {
 	h := &HTTPServerConfigHandler{config}
 	return h
 }
 
+// RouteGroup ...
+func (h *HTTPServerConfigHandler) RouteGroup() route.Group {
+	return route.Group{
+		Admin: true,
+		Routes: []route.Route{
+			{Path: "/config", Methods: []string{"GET"}, Handler: h},
+		},
+	}
+}
+
 func (h *HTTPServerConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	problem.HandlerFunc(h.serveConfig).ServeHTTP(w, r)
+}
+
+// ========== Private Helpers ==========
+
+func (h *HTTPServerConfigHandler) serveConfig(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Add("Vary", "Accept")
+	pretty := false
+	if value := r.URL.Query().Get("pretty"); value != "" {
+		var err error
+		if pretty, err = strconv.ParseBool(value); err != nil {
+			return problem.BadRequest("The pretty query parameter must be a boolean")
+		}
+	}
+
+	contentType, ok := negotiateConfigContentType(r.Header.Get("Accept"))
+	if !ok {
+		return problem.Newf(http.StatusNotAcceptable, "Configuration is available as (%s|%s)", "application/json", "application/yaml")
+	}
+
+	resp := configResponse{Config: h.config.View(), Sources: h.config.Sources}
+	var body []byte
+	var err error
+	switch {
+	case contentType == yamlContentType:
+		body, err = yaml.Marshal(resp)
+	case pretty:
+		body, err = json.MarshalIndent(resp, "", "  ")
+	default:
+		body, err = json.Marshal(resp)
+	}
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(h.config.ToJSONString(false /*prettyPrint*/))) // nolint:errcheck
+	w.Write(body) // nolint:errcheck
+	return nil
+}
+
+// Returns the content type preferred by the given Accept header, or false if neither
+// JSON nor YAML is acceptable. JSON is preferred when both are equally acceptable.
+func negotiateConfigContentType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return jsonContentType, true
+	}
+
+	// More specific media ranges take precedence over wildcards
+	jsonQ, yamlQ, wildcardQ := -1.0, -1.0, -1.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			yamlQ = max(yamlQ, q)
+		case "application/*", "*/*":
+			wildcardQ = max(wildcardQ, q)
+		}
+	}
+	if jsonQ < 0 {
+		jsonQ = wildcardQ
+	}
+	if yamlQ < 0 {
+		yamlQ = wildcardQ
+	}
 
+	switch {
+	case jsonQ > 0 && jsonQ >= yamlQ:
+		return jsonContentType, true
+	case yamlQ > 0:
+		return yamlContentType, true
+	default:
+		return "", false
+	}
 }
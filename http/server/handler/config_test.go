@@ -5,31 +5,141 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/sethvargo/go-envconfig"
 	"github.com/spals/starter-kit/http/server/config"
 	"github.com/spals/starter-kit/http/server/handler"
 	"github.com/stretchr/testify/assert"
+	"go.yaml.in/yaml/v3"
 )
 
+// The response body of HTTPServerConfigHandler
+type configResponse struct {
+	Config  map[string]interface{} `json:"config" yaml:"config"`
+	Sources map[string]string      `json:"sources" yaml:"sources"`
+}
+
+func makeConfigServer(t *testing.T) *httptest.Server {
+	configMap := map[string]string{
+		"LOG_LEVEL":        "info",
+		"PORT":             "18080",
+		"DEBUG_AUTH_TOKEN": "s3cr3t",
+	}
+	httpServerConfig, err := config.NewHTTPServerConfig(envconfig.MapLookuper(configMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := handler.NewHTTPServerConfigHandler(httpServerConfig)
+	return httptest.NewServer(handler)
+}
+
+func getConfig(t *testing.T, url string, accept string) (*http.Response, []byte) {
+	req, _ := http.NewRequest("GET", url, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp, body
+}
+
 func TestHTTPServerConfigHandler(t *testing.T) {
-	handler := handler.NewHTTPServerConfigHandler(&config.HTTPServerConfig{Port: 18080})
-	server := httptest.NewServer(handler)
+	server := makeConfigServer(t)
 	defer server.Close()
 
 	assert := assert.New(t)
-	resp, respErr := http.Get(server.URL)
-	if assert.NoError(respErr) {
+	resp, body := getConfig(t, server.URL, "")
+	if assert.NotNil(resp) {
 		assert.Equal(200, resp.StatusCode)
 		assert.Equal("application/json; charset=utf-8", resp.Header.Get("Content-Type"))
 	}
 
-	body, bodyErr := ioutil.ReadAll(resp.Body)
-	if assert.NoError(bodyErr) {
-		respConfig := config.HTTPServerConfig{}
-		respConfigErr := json.Unmarshal(body, &respConfig)
-		if assert.NoError(respConfigErr) {
-			assert.Equal(18080, respConfig.Port)
-		}
+	var respConfig configResponse
+	if assert.NoError(json.Unmarshal(body, &respConfig)) {
+		assert.Equal(float64(18080), respConfig.Config["Port"])
+		assert.Equal("1s", respConfig.Config["ShutdownTimeout"])
+		assert.Equal(float64(100), respConfig.Config["LivenessConfig"].(map[string]interface{})["MaxGoRoutines"])
+		// Internal fields are excluded
+		assert.NotContains(respConfig.Config, "ReqLogger")
+		assert.NotContains(respConfig.Config, "Sources")
+		// Secrets are redacted
+		assert.Equal("[REDACTED]", respConfig.Config["DebugConfig"].(map[string]interface{})["AuthToken"])
+		assert.NotContains(string(body), "s3cr3t")
+
+		assert.Equal("env", respConfig.Sources["Port"])
+		assert.Equal("env", respConfig.Sources["DebugConfig.AuthToken"])
+		assert.Equal("default", respConfig.Sources["ShutdownTimeout"])
+		assert.Equal("default", respConfig.Sources["LivenessConfig.MaxGoRoutines"])
+	}
+}
+
+func TestHTTPServerConfigHandlerPretty(t *testing.T) {
+	server := makeConfigServer(t)
+	defer server.Close()
+
+	assert := assert.New(t)
+	_, compact := getConfig(t, server.URL, "")
+	assert.NotContains(string(compact), "\n")
+
+	resp, pretty := getConfig(t, server.URL+"?pretty=true", "")
+	if assert.NotNil(resp) {
+		assert.Equal(200, resp.StatusCode)
+		assert.Contains(string(pretty), "\n  \"config\": {")
+	}
+
+	resp, _ = getConfig(t, server.URL+"?pretty=maybe", "")
+	if assert.NotNil(resp) {
+		assert.Equal(400, resp.StatusCode)
+		assert.Equal("application/problem+json", resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestHTTPServerConfigHandlerNegotiation(t *testing.T) {
+	server := makeConfigServer(t)
+	defer server.Close()
+
+	testCases := map[string]struct {
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+	}{
+		"json":              {"application/json", 200, "application/json; charset=utf-8"},
+		"yaml":              {"application/yaml", 200, "application/yaml; charset=utf-8"},
+		"x-yaml":            {"application/x-yaml", 200, "application/yaml; charset=utf-8"},
+		"wildcard":          {"*/*", 200, "application/json; charset=utf-8"},
+		"preferred yaml":    {"application/json;q=0.5, text/yaml", 200, "application/yaml; charset=utf-8"},
+		"excluded json":     {"application/json;q=0, */*", 200, "application/yaml; charset=utf-8"},
+		"browser":           {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", 200, "application/json; charset=utf-8"},
+		"not acceptable":    {"text/html", 406, "application/problem+json"},
+		"malformed ignored": {"garbage;;, application/yaml", 200, "application/yaml; charset=utf-8"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			resp, body := getConfig(t, server.URL, tc.accept)
+			if !assert.NotNil(resp) {
+				return
+			}
+			assert.Equal(tc.expectedStatus, resp.StatusCode)
+			assert.Equal(tc.expectedContentType, resp.Header.Get("Content-Type"))
+			assert.Equal("Accept", resp.Header.Get("Vary"))
+
+			if strings.HasPrefix(tc.expectedContentType, "application/yaml") {
+				var respConfig configResponse
+				if assert.NoError(yaml.Unmarshal(body, &respConfig)) {
+					assert.Equal(18080, respConfig.Config["Port"])
+					assert.Equal("env", respConfig.Sources["Port"])
+					assert.NotContains(string(body), "s3cr3t")
+				}
+			}
+		})
 	}
 }
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+	"github.com/rs/zerolog/log"
+	"github.com/spals/starter-kit/http/server/auth"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/route"
+)
+
+// The time left to write a CPU profile or trace before the server WriteTimeout
+const profileWriteMargin = time.Second
+
+// DebugRouteProvider ...
+// Provides runtime diagnostics routes for HTTPServer:
+//
+//	/debug/pprof/      - profiles (see https://pkg.go.dev/net/http/pprof)
+//	/debug/vars        - exported variables (see https://pkg.go.dev/expvar)
+//	/debug/goroutines  - a full dump of all goroutine stacks
+//
+// CPU profiles and traces are shortened to complete within the configured server
+// WriteTimeout (see boundProfileSeconds).
+type DebugRouteProvider struct {
+	config *config.HTTPServerConfig
+}
+
+// NewDebugRouteProvider ...
+func NewDebugRouteProvider(config *config.HTTPServerConfig) *DebugRouteProvider {
+	p := &DebugRouteProvider{config}
+	return p
+}
+
+// RouteGroup ...
+func (p *DebugRouteProvider) RouteGroup() route.Group {
+	if !p.config.DebugEnabled() {
+		return route.Group{}
+	}
+	// Outside of Dev mode, debug endpoints must not be publicly accessible
+	if !p.config.Dev && !p.config.AdminEnabled() && p.config.DebugConfig.AuthToken == "" {
+		log.Warn().Msg("Debug endpoints require the admin server or an auth token when not in Dev mode. Not serving debug endpoints")
+		return route.Group{}
+	}
+
+	middleware := []mux.MiddlewareFunc{logDebugAccess}
+	if p.config.DebugConfig.AuthToken != "" {
+		middleware = append(middleware, auth.StaticTokenHandler("debug", p.config.DebugConfig.AuthToken))
+	}
+	return route.Group{
+		PathPrefix: "/debug",
+		Middleware: middleware,
+		Admin:      true,
+		Routes: []route.Route{
+			{Path: "/pprof/", Methods: []string{"GET"}, Handler: http.HandlerFunc(pprof.Index)},
+			{Path: "/pprof/cmdline", Methods: []string{"GET"}, Handler: http.HandlerFunc(pprof.Cmdline)},
+			{Path: "/pprof/profile", Methods: []string{"GET"}, Handler: boundProfileSeconds(pprof.Profile, 30 /*defaultSeconds*/)},
+			{Path: "/pprof/symbol", Methods: []string{"GET", "POST"}, Handler: http.HandlerFunc(pprof.Symbol)},
+			{Path: "/pprof/trace", Methods: []string{"GET"}, Handler: boundProfileSeconds(pprof.Trace, 1 /*defaultSeconds*/)},
+			// Named profiles (e.g. heap, goroutine, block) are served by the index handler
+			{Path: "/pprof/{profile}", Methods: []string{"GET"}, Handler: http.HandlerFunc(pprof.Index)},
+			{Path: "/vars", Methods: []string{"GET"}, Handler: expvar.Handler()},
+			{Path: "/goroutines", Methods: []string{"GET"}, Handler: http.HandlerFunc(serveGoroutineDump)},
+		},
+	}
+}
+
+// ========== Private Helpers ==========
+
+// Logs each access to a debug endpoint, as they expose sensitive runtime information
+func logDebugAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hlog.FromRequest(r).Info().
+			Str("path", r.URL.Path).
+			Str("query", r.URL.RawQuery).
+			Msg("Debug endpoint accessed")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Caps the duration (i.e. the seconds query parameter) of CPU profiles and traces so that
+// they complete within the server WriteTimeout, leaving a margin to write the response.
+// Otherwise, pprof rejects profiles which would outlast the WriteTimeout, including the
+// default 30 second CPU profile under the default WriteTimeout.
+func boundProfileSeconds(next http.HandlerFunc, defaultSeconds int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server)
+		if !ok || srv.WriteTimeout <= 0 {
+			next(w, r)
+			return
+		}
+
+		seconds := defaultSeconds
+		if value := r.URL.Query().Get("seconds"); value != "" {
+			var err error
+			if seconds, err = strconv.ParseInt(value, 10, 64); err != nil {
+				// Let pprof reject the invalid value
+				next(w, r)
+				return
+			}
+		}
+		maxSeconds := int64((srv.WriteTimeout - profileWriteMargin) / time.Second)
+		if maxSeconds < 1 {
+			maxSeconds = 1
+		}
+		if seconds > maxSeconds {
+			hlog.FromRequest(r).Info().
+				Int64("seconds", seconds).
+				Int64("max_seconds", maxSeconds).
+				Msg("Shortening profile to complete within the server WriteTimeout")
+			query := r.URL.Query()
+			query.Set("seconds", strconv.FormatInt(maxSeconds, 10))
+			r = r.Clone(r.Context())
+			r.URL.RawQuery = query.Encode()
+		}
+		next(w, r)
+	})
+}
+
+// Writes the stacks of all goroutines in the same format as an unrecovered panic
+func serveGoroutineDump(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2 /*debug*/) // nolint:errcheck
+}
@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/handler"
+	"github.com/spals/starter-kit/http/server/route"
+	"github.com/stretchr/testify/assert"
+)
+
+// Serves the given request from a router with the debug routes for the given configuration
+func serveDebug(httpServerConfig *config.HTTPServerConfig, req *http.Request) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	route.Register(router, handler.NewDebugRouteProvider(httpServerConfig).RouteGroup())
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestDebugRoutesDev(t *testing.T) {
+	assert := assert.New(t)
+	httpServerConfig := &config.HTTPServerConfig{Dev: true, AdminPort: -1, DebugConfig: &config.DebugConfig{}}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline", "/debug/vars"} {
+		assert.Equal(200, serveDebug(httpServerConfig, httptest.NewRequest("GET", path, nil)).Code, path)
+	}
+
+	recorder := serveDebug(httpServerConfig, httptest.NewRequest("GET", "/debug/goroutines", nil))
+	assert.Equal(200, recorder.Code)
+	body, _ := ioutil.ReadAll(recorder.Body)
+	assert.Contains(string(body), "goroutine ")
+	assert.Contains(string(body), "TestDebugRoutesDev")
+}
+
+func TestDebugProfileWriteTimeout(t *testing.T) {
+	assert := assert.New(t)
+	httpServerConfig := &config.HTTPServerConfig{Dev: true, AdminPort: -1, DebugConfig: &config.DebugConfig{}}
+
+	// Profiles which would outlast the server WriteTimeout are shortened rather than rejected
+	req := httptest.NewRequest("GET", "/debug/pprof/profile?seconds=60", nil)
+	req = req.WithContext(context.WithValue(req.Context(), http.ServerContextKey, &http.Server{WriteTimeout: 2 * time.Second}))
+	start := time.Now()
+	recorder := serveDebug(httpServerConfig, req)
+	assert.Equal(200, recorder.Code)
+	assert.Less(time.Since(start), 2*time.Second)
+}
+
+func TestDebugRoutesDisabled(t *testing.T) {
+	for name, httpServerConfig := range map[string]*config.HTTPServerConfig{
+		// Debug endpoints are disabled by default outside of Dev mode
+		"default": {AdminPort: 0, DebugConfig: &config.DebugConfig{}},
+		// Debug endpoints must not be served publicly outside of Dev mode
+		"public": {AdminPort: -1, DebugConfig: &config.DebugConfig{Enabled: true}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, 404, serveDebug(httpServerConfig, httptest.NewRequest("GET", "/debug/vars", nil)).Code)
+		})
+	}
+}
+
+func TestDebugRoutesAdmin(t *testing.T) {
+	httpServerConfig := &config.HTTPServerConfig{AdminPort: 0, DebugConfig: &config.DebugConfig{Enabled: true}}
+
+	assert.True(t, handler.NewDebugRouteProvider(httpServerConfig).RouteGroup().Admin)
+	assert.Equal(t, 200, serveDebug(httpServerConfig, httptest.NewRequest("GET", "/debug/vars", nil)).Code)
+}
+
+func TestDebugRoutesAuthToken(t *testing.T) {
+	assert := assert.New(t)
+	httpServerConfig := &config.HTTPServerConfig{AdminPort: -1, DebugConfig: &config.DebugConfig{Enabled: true, AuthToken: "s3cret"}}
+
+	recorder := serveDebug(httpServerConfig, httptest.NewRequest("GET", "/debug/vars", nil))
+	assert.Equal(401, recorder.Code)
+	assert.Equal(`Bearer realm="debug"`, recorder.Header().Get("WWW-Authenticate"))
+
+	req := httptest.NewRequest("GET", "/debug/vars", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	assert.Equal(401, serveDebug(httpServerConfig, req).Code)
+
+	req = httptest.NewRequest("GET", "/debug/vars", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	assert.Equal(200, serveDebug(httpServerConfig, req).Code)
+}
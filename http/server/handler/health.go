@@ -1,28 +1,72 @@
 package handler
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/heptiolabs/healthcheck"
 	"github.com/rs/zerolog/log"
 	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/spals/starter-kit/http/server/route"
 )
 
+// HealthCheckRouteProvider ...
+// Provides live and readiness check routes for HTTPServer
+type HealthCheckRouteProvider struct {
+	healthCheckHandler *healthcheck.Handler
+}
+
 // NewHealthCheckHandler ...
 // Creates live and readiness checks based off of HTTPServer configuration
 //
 // See https://github.com/heptiolabs/healthcheck/blob/master/README.md
-func NewHealthCheckHandler(config *config.HTTPServerConfig) *healthcheck.Handler {
+func NewHealthCheckHandler(config *config.HTTPServerConfig, panicCounter *middleware.PanicCounter) *healthcheck.Handler {
 	healthCheckHandler := healthcheck.NewHandler()
-	configureLivenessChecks(config, healthCheckHandler)
+	configureLivenessChecks(config, healthCheckHandler, panicCounter)
 	configureReadinessChecks(config, healthCheckHandler)
 
 	return &healthCheckHandler
 }
 
+// NewHealthCheckRouteProvider ...
+func NewHealthCheckRouteProvider(healthCheckHandler *healthcheck.Handler) *HealthCheckRouteProvider {
+	p := &HealthCheckRouteProvider{healthCheckHandler}
+	return p
+}
+
+// RouteGroup ...
+func (p *HealthCheckRouteProvider) RouteGroup() route.Group {
+	return route.Group{
+		Admin: true,
+		Routes: []route.Route{
+			{Path: "/live", Methods: []string{"GET"}, Handler: http.HandlerFunc((*p.healthCheckHandler).LiveEndpoint)},
+			{Path: "/ready", Methods: []string{"GET"}, Handler: http.HandlerFunc((*p.healthCheckHandler).ReadyEndpoint)},
+		},
+	}
+}
+
 // ========== Private Helpers ==========
 
-func configureLivenessChecks(config *config.HTTPServerConfig, healthCheckHandler healthcheck.Handler) {
+func configureLivenessChecks(config *config.HTTPServerConfig, healthCheckHandler healthcheck.Handler, panicCounter *middleware.PanicCounter) {
 	log.Debug().Str("name", "goroutine-threshold").Int("check", config.LivenessConfig.MaxGoRoutines).Msg("Adding liveness check")
 	healthCheckHandler.AddLivenessCheck("goroutine-threshold", healthcheck.GoroutineCountCheck(config.LivenessConfig.MaxGoRoutines))
+
+	if config.LivenessConfig.MaxPanics > 0 {
+		log.Debug().Str("name", "panic-threshold").Int("check", config.LivenessConfig.MaxPanics).Msg("Adding liveness check")
+		healthCheckHandler.AddLivenessCheck("panic-threshold", panicCountCheck(panicCounter, config.LivenessConfig.MaxPanics))
+	}
+}
+
+// Fails if the number of recovered handler panics exceeds the given threshold
+func panicCountCheck(panicCounter *middleware.PanicCounter, threshold int) healthcheck.Check {
+	return func() error {
+		count := panicCounter.Count()
+		if count > int64(threshold) {
+			return fmt.Errorf("too many recovered panics (%d > %d)", count, threshold)
+		}
+		return nil
+	}
 }
 
 func configureReadinessChecks(config *config.HTTPServerConfig, healthCheckHandler healthcheck.Handler) {
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/spals/starter-kit/http/server/route"
+)
+
+// MetricsRouteProvider ...
+// Provides the Prometheus metrics route for HTTPServer
+type MetricsRouteProvider struct {
+	metrics *middleware.Metrics
+}
+
+// NewMetricsRouteProvider ...
+func NewMetricsRouteProvider(metrics *middleware.Metrics) *MetricsRouteProvider {
+	p := &MetricsRouteProvider{metrics}
+	return p
+}
+
+// RouteGroup ...
+func (p *MetricsRouteProvider) RouteGroup() route.Group {
+	return route.Group{
+		Admin: true,
+		Routes: []route.Route{
+			{Path: "/metrics", Methods: []string{"GET"}, Handler: p.metrics.Handler()},
+		},
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"github.com/google/wire"
+	"github.com/spals/starter-kit/http/server/route"
+)
+
+// ProviderSet ...
+// All handler constructors to be injected into HTTPServer (see wire.go)
+//
+// New handlers should add their constructor here and to NewRouteProviders.
+var ProviderSet = wire.NewSet(
+	NewHealthCheckHandler,
+	NewHealthCheckRouteProvider,
+	NewHTTPServerConfigHandler,
+	NewMetricsRouteProvider,
+	NewDebugRouteProvider,
+	NewRouteProviders,
+)
+
+// NewRouteProviders ...
+// Collects all route providers to be registered in the HTTPServer router
+func NewRouteProviders(
+	healthCheckRouteProvider *HealthCheckRouteProvider,
+	httpServerConfigHandler *HTTPServerConfigHandler,
+	metricsRouteProvider *MetricsRouteProvider,
+	debugRouteProvider *DebugRouteProvider,
+) []route.Provider {
+	return []route.Provider{
+		healthCheckRouteProvider,
+		httpServerConfigHandler,
+		metricsRouteProvider,
+		debugRouteProvider,
+	}
+}
@@ -0,0 +1,53 @@
+// Package servertest provides fixtures shared by the tests of the server packages
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/spals/starter-kit/http/server/route"
+)
+
+// Router ...
+// Creates a router with the given route group and mux middleware
+func Router(group route.Group, middleware ...mux.MiddlewareFunc) *mux.Router {
+	router := mux.NewRouter()
+	route.Register(router, group)
+	router.Use(middleware...)
+	return router
+}
+
+// Handler ...
+// Wraps the given handler in a request logger and an access log which write to the given
+// writer, in the same order as the server. Access logs record the response status and size.
+func Handler(logs io.Writer, handler http.Handler) http.Handler {
+	accessLog := hlog.AccessHandler(func(r *http.Request, status, size int, duration time.Duration) {
+		hlog.FromRequest(r).Info().Int("status", status).Int("size", size).Msg("Finished HTTP request")
+	})
+	return hlog.NewHandler(zerolog.New(logs))(accessLog(hlog.RequestIDHandler("req_id", "Request-Id")(handler)))
+}
+
+// LogEntries ...
+// Parses each JSON log entry written to the given logs, in order
+func LogEntries(t testing.TB, logs []byte) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(logs), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("invalid log entry (%s): %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
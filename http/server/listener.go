@@ -0,0 +1,152 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Supported schemes for HTTPServerConfig.Listen
+const (
+	// Listen on a TCP address (e.g. tcp://:8080 or tcp://127.0.0.1:8080)
+	listenSchemeTCP = "tcp://"
+	// Listen on a Unix domain socket (e.g. unix:///run/app.sock)
+	listenSchemeUnix = "unix://"
+	// Use a socket passed by systemd socket activation (e.g. systemd: or systemd:http to select a named socket)
+	// See https://www.freedesktop.org/software/systemd/man/sd_listen_fds.html
+	listenSchemeSystemd = "systemd:"
+)
+
+// The first file descriptor passed by systemd socket activation
+const systemdListenFDsStart = 3
+
+// The time to wait while checking whether an existing Unix domain socket is still being served
+const unixSocketDialTimeout = time.Second
+
+// Creates the listener on which the server accepts connections.
+//
+// A listener inherited from a parent process during a binary upgrade takes precedence.
+// Otherwise, if no listen address is configured, then the server listens on the configured TCP port.
+func (s *HTTPServer) makeListener() (net.Listener, error) {
+	if listener, err := inheritedListener(upgradeListenerFDEnv); listener != nil || err != nil {
+		if listener != nil {
+			s.overwritePort(listener)
+		}
+		return listener, err
+	}
+
+	listen := s.config.Listen
+	switch {
+	case listen == "":
+		return s.makeTCPListener(fmt.Sprint(":", s.config.Port))
+	case strings.HasPrefix(listen, listenSchemeTCP):
+		return s.makeTCPListener(strings.TrimPrefix(listen, listenSchemeTCP))
+	case strings.HasPrefix(listen, listenSchemeUnix):
+		return makeUnixListener(strings.TrimPrefix(listen, listenSchemeUnix))
+	case strings.HasPrefix(listen, listenSchemeSystemd):
+		return makeSystemdListener(strings.TrimPrefix(listen, listenSchemeSystemd))
+	default:
+		return nil, fmt.Errorf("unsupported listen address (%s). Available schemes are (tcp://|unix://|systemd:)", listen)
+	}
+}
+
+// If a random port is requested, then the listener is made on an open port and the
+// configured port is overwritten
+// See https://stackoverflow.com/questions/43424787/how-to-use-next-available-port-in-http-listenandserve
+func (s *HTTPServer) makeTCPListener(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error while listening on TCP address (%s): %w", addr, err)
+	}
+
+	s.overwritePort(listener)
+	return listener, nil
+}
+
+// Overwrites the configured port with the active port of a TCP listener
+func (s *HTTPServer) overwritePort(listener net.Listener) {
+	tcpAddr, ok := listener.Addr().(*net.TCPAddr)
+	if ok && s.config.Port != tcpAddr.Port {
+		log.Info().Msgf("Overwriting configured port (%d) with active port (%d)", s.config.Port, tcpAddr.Port)
+		s.config.Port = tcpAddr.Port
+		s.config.SetRuntimeSource("Port")
+	}
+}
+
+func makeUnixListener(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no Unix domain socket path configured")
+	}
+
+	// Remove a stale socket left behind by a previous process. A socket which is still being
+	// served by another process and any other type of file are left untouched.
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, unixSocketDialTimeout)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("error while listening on Unix domain socket (%s): %w", path, syscall.EADDRINUSE)
+		} else if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("error while checking for a stale Unix domain socket (%s): %w", path, err)
+		}
+
+		log.Debug().Str("path", path).Msg("Removing stale Unix domain socket")
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error while removing stale Unix domain socket (%s): %w", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("error while listening on Unix domain socket (%s): %w", path, err)
+	}
+	return listener, nil
+}
+
+// Creates a listener from a socket passed by systemd. If a name is given, then the
+// socket with the matching name in LISTEN_FDNAMES is used. Otherwise, the first socket is used.
+func makeSystemdListener(name string) (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("no sockets passed by systemd for this process (LISTEN_PID=%s)", os.Getenv("LISTEN_PID"))
+	}
+	numFDs, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || numFDs < 1 {
+		return nil, fmt.Errorf("no sockets passed by systemd (LISTEN_FDS=%s)", os.Getenv("LISTEN_FDS"))
+	}
+
+	index := 0
+	if name != "" {
+		index = -1
+		for i, fdName := range strings.Split(os.Getenv("LISTEN_FDNAMES"), ":") {
+			if fdName == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 || index >= numFDs {
+			return nil, fmt.Errorf("no socket named (%s) passed by systemd (LISTEN_FDNAMES=%s)", name, os.Getenv("LISTEN_FDNAMES"))
+		}
+	}
+
+	// Unset the systemd variables so that they are not inherited by child processes
+	os.Unsetenv("LISTEN_PID")     // nolint:errcheck
+	os.Unsetenv("LISTEN_FDS")     // nolint:errcheck
+	os.Unsetenv("LISTEN_FDNAMES") // nolint:errcheck
+
+	fd := uintptr(systemdListenFDsStart + index)
+	file := os.NewFile(fd, fmt.Sprintf("systemd-socket-%d", fd))
+	defer file.Close()
+
+	// Note that the listener holds a duplicate of the file descriptor
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("error while listening on systemd socket (fd %d): %w", fd, err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,124 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenTCP(t *testing.T) {
+	assert := assert.New(t)
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["LISTEN"] = "tcp://127.0.0.1:0"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	urlBase := runTestServer(t, httpServer, &http.Client{}, "http")
+	if assert.IsType(&net.TCPAddr{}, httpServer.ActiveAddr()) {
+		assert.Equal("127.0.0.1", httpServer.ActiveAddr().(*net.TCPAddr).IP.String())
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/config", urlBase))
+	if assert.NoError(err) {
+		defer resp.Body.Close()
+		assert.Equal(200, resp.StatusCode)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	assert := assert.New(t)
+	socketPath := filepath.Join(t.TempDir(), "app.sock")
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["LISTEN"] = fmt.Sprintf("unix://%s", socketPath)
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		assert.NoError(<-runErr)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	assert.Eventually(func() bool {
+		resp, err := client.Get("http://unix/ready")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == 200
+	}, serverStartTimeoutMs*time.Millisecond /*waitFor*/, serverStartTickMs*time.Millisecond /*tick*/)
+
+	if assert.NotNil(httpServer.ActiveAddr()) {
+		assert.Equal("unix", httpServer.ActiveAddr().Network())
+		assert.Equal(socketPath, httpServer.ActiveAddr().String())
+	}
+	assert.Equal(0, httpServer.ActivePort())
+}
+
+func TestListenUnixExistingSocket(t *testing.T) {
+	assert := assert.New(t)
+	socketPath := filepath.Join(t.TempDir(), "app.sock")
+	configMap := map[string]string{"LOG_LEVEL": "trace", "LISTEN": fmt.Sprintf("unix://%s", socketPath)}
+
+	// A socket which is still being served is not taken over
+	active, err := net.Listen("unix", socketPath)
+	if !assert.NoError(err) {
+		return
+	}
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+	err = httpServer.Run(context.Background())
+	assert.True(errors.Is(err, syscall.EADDRINUSE))
+
+	// A stale socket left behind by a previous process is replaced
+	active.(*net.UnixListener).SetUnlinkOnClose(false)
+	active.Close()
+	httpServer, _ = server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+	assert.Eventually(func() bool {
+		return httpServer.ActiveAddr() != nil
+	}, serverStartTimeoutMs*time.Millisecond /*waitFor*/, serverStartTickMs*time.Millisecond /*tick*/)
+	cancel()
+	assert.NoError(<-runErr)
+}
+
+func TestListenInvalidConfig(t *testing.T) {
+	for name, configMap := range map[string]map[string]string{
+		"unknown scheme":        {"LISTEN": "udp://:8080"},
+		"empty unix path":       {"LISTEN": "unix://"},
+		"no systemd sockets":    {"LISTEN": "systemd:"},
+		"h3 with a unix socket": {"LISTEN": fmt.Sprintf("unix://%s", filepath.Join(t.TempDir(), "app.sock")), "PROTOCOL": "h3"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			configMap["LOG_LEVEL"] = "trace"
+			if configMap["PROTOCOL"] == "h3" {
+				configMap["TLS_CERT_FILE"], configMap["TLS_KEY_FILE"] = writeTestCert(t, t.TempDir(), newTestServerCert(t, "server", nil /*parent*/))
+			}
+			httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+			assert.Error(t, httpServer.Run(context.Background()))
+		})
+	}
+}
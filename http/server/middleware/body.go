@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+	"github.com/spals/starter-kit/http/server/problem"
+	"github.com/spals/starter-kit/http/server/route"
+)
+
+// RequestBody ...
+// Middleware which limits the size of request bodies and enforces the request content
+// types declared by each route (see route.Route). Bodies are limited to the given
+// default size unless the matched route overrides it.
+//
+// Requests which declare a body larger than the limit are rejected with a problem+json 413
+// response. Chunked bodies are wrapped with http.MaxBytesReader, so handlers receive an
+// *http.MaxBytesError once the limit is exceeded. Requests with a body whose content type
+// is not accepted by the route are rejected with a problem+json 415 response. All
+// violations are logged with the route name.
+//
+// Route limits depend on the matched route (see route.Current).
+func RequestBody(defaultMaxBytes int64) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			registeredRoute, _ := route.Current(r)
+			var pathTemplate string
+			if muxRoute := mux.CurrentRoute(r); muxRoute != nil {
+				pathTemplate, _ = muxRoute.GetPathTemplate()
+			}
+			logViolation := func(msg string) {
+				hlog.FromRequest(r).Warn().
+					Str("route", pathTemplate).
+					Str("route_name", registeredRoute.Name).
+					Int64("content_length", r.ContentLength).
+					Str("content_type", r.Header.Get("Content-Type")).
+					Msg(msg)
+			}
+
+			maxBytes := defaultMaxBytes
+			if registeredRoute.MaxBodyBytes != 0 {
+				maxBytes = registeredRoute.MaxBodyBytes
+			}
+			if maxBytes > 0 && r.ContentLength > maxBytes {
+				logViolation("Rejected request body which exceeds the maximum size")
+				problem.Write(w, r, problem.Newf(http.StatusRequestEntityTooLarge,
+					"Request body exceeds the maximum size of %d bytes", maxBytes).With("route", pathTemplate))
+				return
+			}
+
+			if hasBody(r) && len(registeredRoute.ContentTypes) > 0 && !acceptsContentType(registeredRoute.ContentTypes, r.Header.Get("Content-Type")) {
+				logViolation("Rejected request body with an unsupported content type")
+				problem.Write(w, r, problem.Newf(http.StatusUnsupportedMediaType,
+					"Request content type must be one of (%s)", strings.Join(registeredRoute.ContentTypes, "|")).With("route", pathTemplate))
+				return
+			}
+
+			if maxBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+				r.Body = &limitedBody{
+					ReadCloser: http.MaxBytesReader(w, r.Body, maxBytes),
+					onExceeded: func() { logViolation("Request body exceeded the maximum size while reading") },
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+// A request body which reports the first time its size limit is exceeded
+type limitedBody struct {
+	io.ReadCloser
+	onExceeded func()
+	exceeded   bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if !b.exceeded && errors.As(err, &maxBytesErr) {
+		b.exceeded = true
+		b.onExceeded()
+	}
+	return n, err
+}
+
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || (r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody)
+}
+
+// Returns true if the given content type matches one of the given accepted content types
+func acceptsContentType(accepted []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && matchMediaType(accepted, mediaType)
+}
+
+// Returns true if the given media type matches one of the given patterns. A pattern
+// ending in /* matches all subtypes (e.g. text/*).
+func matchMediaType(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == mediaType || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spals/starter-kit/http/server/internal/servertest"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/spals/starter-kit/http/server/route"
+	"github.com/stretchr/testify/assert"
+)
+
+// Reads the request body and responds with the number of bytes read, or a 400 if the body could not be read
+func readBodyHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprint(len(body)))) // nolint:errcheck
+}
+
+// Creates a router with routes which declare body limits and content types, wrapped in a
+// request logger and access log which write to the given writer
+func makeBodyRouter(logs io.Writer) http.Handler {
+	router := servertest.Router(route.Group{
+		Routes: []route.Route{
+			{Name: "default", Path: "/default", Handler: http.HandlerFunc(readBodyHandler)},
+			{Name: "upload", Path: "/upload", Handler: http.HandlerFunc(readBodyHandler), MaxBodyBytes: 64, ContentTypes: []string{"image/*"}},
+			{Name: "unlimited", Path: "/unlimited", Handler: http.HandlerFunc(readBodyHandler), MaxBodyBytes: -1},
+			{Name: "json", Path: "/json", Handler: http.HandlerFunc(readBodyHandler), ContentTypes: []string{"application/json"}},
+		},
+	}, middleware.RequestBody(16 /*defaultMaxBytes*/))
+	return servertest.Handler(logs, router)
+}
+
+func serveBody(handler http.Handler, path string, contentType string, body io.Reader, contentLength int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, body)
+	req.ContentLength = contentLength
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRequestBodyLimits(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		path          string
+		size          int
+		expected      int
+		expectedLog   string
+		contentLength bool
+	}{
+		"within default":          {"/default", 16, http.StatusOK, "", true},
+		"exceeds default":         {"/default", 17, http.StatusRequestEntityTooLarge, `"route_name":"default"`, true},
+		"exceeds default chunked": {"/default", 17, http.StatusBadRequest, `"route_name":"default"`, false},
+		"within route override":   {"/upload", 64, http.StatusOK, "", true},
+		"exceeds route override":  {"/upload", 65, http.StatusRequestEntityTooLarge, `"route_name":"upload"`, true},
+		"unlimited":               {"/unlimited", 4096, http.StatusOK, "", true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			logs := &bytes.Buffer{}
+			router := makeBodyRouter(logs)
+
+			body := strings.Repeat("a", testCase.size)
+			contentLength := int64(-1)
+			if testCase.contentLength {
+				contentLength = int64(testCase.size)
+			}
+			// Hide the length of the body from the request in order to simulate a chunked body
+			recorder := serveBody(router, testCase.path, "image/png", io.MultiReader(strings.NewReader(body)), contentLength)
+			assert.Equal(testCase.expected, recorder.Code)
+			if testCase.expected == http.StatusRequestEntityTooLarge {
+				var respBody map[string]interface{}
+				if assert.NoError(json.Unmarshal(recorder.Body.Bytes(), &respBody)) {
+					assert.Equal(float64(413), respBody["status"])
+					assert.Equal(testCase.path, respBody["route"])
+				}
+			}
+			if testCase.expectedLog != "" {
+				assert.Contains(logs.String(), testCase.expectedLog)
+			} else {
+				assert.NotContains(logs.String(), "route_name")
+			}
+		})
+	}
+}
+
+func TestRequestBodyContentTypes(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		path        string
+		contentType string
+		body        string
+		expected    int
+	}{
+		"accepted":             {"/json", "application/json; charset=utf-8", "{}", http.StatusOK},
+		"accepted wildcard":    {"/upload", "image/png", "png", http.StatusOK},
+		"unsupported":          {"/json", "text/plain", "{}", http.StatusUnsupportedMediaType},
+		"unsupported wildcard": {"/upload", "text/plain", "png", http.StatusUnsupportedMediaType},
+		"missing":              {"/json", "", "{}", http.StatusUnsupportedMediaType},
+		"no body":              {"/json", "", "", http.StatusOK},
+		"route accepts any":    {"/default", "text/plain", "text", http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			logs := &bytes.Buffer{}
+			router := makeBodyRouter(logs)
+
+			recorder := serveBody(router, testCase.path, testCase.contentType, strings.NewReader(testCase.body), int64(len(testCase.body)))
+			assert.Equal(testCase.expected, recorder.Code)
+			if testCase.expected == http.StatusUnsupportedMediaType {
+				var respBody map[string]interface{}
+				if assert.NoError(json.Unmarshal(recorder.Body.Bytes(), &respBody)) {
+					assert.Equal(float64(415), respBody["status"])
+					assert.NotEmpty(respBody["detail"])
+				}
+				assert.Contains(logs.String(), `"route":"`+testCase.path+`"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog"
+	"github.com/spals/starter-kit/http/server/config"
+)
+
+// Supported values for CompressionConfig.Encodings
+const (
+	encodingZstd   = "zstd"
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// An encoder which can be reset to write to a new destination and therefore pooled
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	encodingZstd: {New: func() interface{} {
+		e, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return e
+	}},
+	encodingBrotli: {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	encodingGzip: {New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}},
+}
+
+// Compress ...
+// Returns middleware which compresses responses using the encoding negotiated via
+// the Accept-Encoding request header. Responses are only compressed if they are at
+// least the configured minimum size and have an allowed content type.
+//
+// Streaming responses (i.e. handlers which flush before the minimum size is reached)
+// and server-sent events are never compressed.
+//
+// The original and compressed sizes of compressed responses are added to the request
+// logger. Note that this must be registered after hlog.NewHandler.
+func Compress(compressionConfig *config.CompressionConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if !compressionConfig.Enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), compressionConfig.Encodings)
+			// Range requests refer to the uncompressed representation and upgraded connections are hijacked
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{w: w, config: compressionConfig, encoding: encoding}
+			next.ServeHTTP(cw, r)
+			cw.close()
+
+			if cw.encoder != nil {
+				zerolog.Ctx(r.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+					return c.Str("encoding", encoding).Int("original_size", cw.originalSize).Int("compressed_size", cw.compressedSize)
+				})
+			}
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+// A response writer which buffers the response until the decision to compress it can be made
+type compressWriter struct {
+	w        http.ResponseWriter
+	config   *config.CompressionConfig
+	encoding string
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	// The encoder used to compress the response, if it is compressed
+	encoder encoder
+
+	originalSize   int
+	compressedSize int
+}
+
+func (cw *compressWriter) Header() http.Header {
+	return cw.w.Header()
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	// Informational responses are sent immediately
+	if code >= 100 && code < 200 {
+		cw.w.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.originalSize += len(p)
+	if cw.decided {
+		return cw.writeThrough(p)
+	}
+
+	cw.buf.Write(p)
+	if cw.buf.Len() >= cw.config.MinSize {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush ...
+// Supports streaming handlers. A response which is flushed before a decision to compress
+// it has been made is treated as a streaming response and is not compressed.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decided = true
+		cw.commit(false /*compress*/) // nolint:errcheck
+	}
+	if cw.encoder != nil {
+		cw.encoder.Flush() // nolint:errcheck
+	}
+	if flusher, ok := cw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap ...
+// Allows http.ResponseController to reach the underlying response writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.w
+}
+
+func (cw *compressWriter) writeThrough(p []byte) (int, error) {
+	if cw.encoder != nil {
+		// The encoder writes to a counting writer, so report the number of original bytes written
+		if _, err := cw.encoder.Write(p); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return cw.w.Write(p)
+}
+
+// Decides whether to compress the response once the minimum size has been reached
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	return cw.commit(cw.compressible())
+}
+
+// Writes the response headers and any buffered response bytes
+func (cw *compressWriter) commit(compress bool) error {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	header := cw.w.Header()
+	if cw.compressibleType() {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if compress {
+		// net/http would otherwise detect the content type of the compressed bytes
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(cw.buf.Bytes()))
+		}
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+		cw.encoder = encoderPools[cw.encoding].Get().(encoder)
+		cw.encoder.Reset(&countingWriter{w: cw.w, n: &cw.compressedSize})
+	}
+	cw.w.WriteHeader(cw.status)
+
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	_, err := cw.writeThrough(cw.buf.Bytes())
+	cw.buf.Reset()
+	return err
+}
+
+// Writes any remaining response bytes once the handler has completed
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		// The response is smaller than the minimum size
+		cw.decided = true
+		cw.commit(false /*compress*/) // nolint:errcheck
+		return
+	}
+	if cw.encoder != nil {
+		cw.encoder.Close() // nolint:errcheck
+		cw.encoder.Reset(nil)
+		encoderPools[cw.encoding].Put(cw.encoder)
+	}
+}
+
+func (cw *compressWriter) compressible() bool {
+	header := cw.w.Header()
+	if cw.status < http.StatusOK || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" ||
+		strings.Contains(header.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	return cw.compressibleType()
+}
+
+func (cw *compressWriter) compressibleType() bool {
+	contentType := cw.w.Header().Get("Content-Type")
+	if contentType == "" {
+		// Match the content type which net/http would detect
+		contentType = http.DetectContentType(cw.buf.Bytes())
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	// Server-sent events are a stream and must never be buffered by compression
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+	return matchMediaType(cw.config.ContentTypes, mediaType)
+}
+
+// Counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n *int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	*cw.n += n
+	return n, err
+}
+
+// Returns the supported encoding with the highest weight in the given Accept-Encoding
+// header, using the order of the supported encodings to break ties. Returns an empty
+// string if no supported encoding is acceptable.
+// See https://httpwg.org/specs/rfc9110.html#field.accept-encoding
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				weight = parsed
+			}
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+
+	type candidate struct {
+		encoding string
+		weight   float64
+	}
+	var candidates []candidate
+	for _, encoding := range supported {
+		if _, ok := encoderPools[encoding]; !ok {
+			continue
+		}
+		weight, ok := weights[encoding]
+		if !ok {
+			weight, ok = weights["*"]
+		}
+		if ok && weight > 0 {
+			candidates = append(candidates, candidate{encoding, weight})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].weight > candidates[j].weight })
+	return candidates[0].encoding
+}
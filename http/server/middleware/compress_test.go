@@ -0,0 +1,165 @@
+package middleware_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/internal/servertest"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+var largeJSON = `{"data":"` + strings.Repeat("a", 4096) + `"}`
+
+func makeCompressionConfig() *config.CompressionConfig {
+	return &config.CompressionConfig{
+		Enabled:      true,
+		Encodings:    []string{"zstd", "br", "gzip"},
+		MinSize:      1024,
+		ContentTypes: []string{"application/json", "text/*"},
+	}
+}
+
+// Wraps the given handler in the compression middleware, a request logger and an access log which
+// write to the given writer
+func makeCompressHandler(logs io.Writer, compressionConfig *config.CompressionConfig, handler http.HandlerFunc) http.Handler {
+	return servertest.Handler(logs, middleware.Compress(compressionConfig)(handler))
+}
+
+func serveJSON(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body)) // nolint:errcheck
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	var reader io.Reader
+	var err error
+	switch encoding {
+	case "gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		var decoder *zstd.Decoder
+		decoder, err = zstd.NewReader(bytes.NewReader(body))
+		if err == nil {
+			defer decoder.Close()
+		}
+		reader = decoder
+	}
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	decoded, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	return string(decoded)
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	for acceptEncoding, expected := range map[string]string{
+		"gzip":                   "gzip",
+		"gzip, deflate, br":      "br",
+		"gzip, br, zstd":         "zstd",
+		"zstd;q=0.5, gzip":       "gzip",
+		"br;q=0, *":              "zstd",
+		"*;q=0.1, gzip;q=0.2":    "gzip",
+		"zstd;q=0, br;q=0, gzip": "gzip",
+	} {
+		t.Run(acceptEncoding, func(t *testing.T) {
+			assert := assert.New(t)
+			logs := &bytes.Buffer{}
+			handler := makeCompressHandler(logs, makeCompressionConfig(), serveJSON(largeJSON))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(http.StatusOK, recorder.Code)
+			assert.Equal(expected, recorder.Header().Get("Content-Encoding"))
+			assert.Equal("Accept-Encoding", recorder.Header().Get("Vary"))
+			assert.Empty(recorder.Header().Get("Content-Length"))
+			assert.Equal(largeJSON, decode(t, expected, recorder.Body.Bytes()))
+
+			var accessLog map[string]interface{}
+			if assert.NoError(json.Unmarshal(logs.Bytes(), &accessLog)) {
+				assert.Equal(expected, accessLog["encoding"])
+				assert.Equal(float64(len(largeJSON)), accessLog["original_size"])
+				assert.Equal(float64(recorder.Body.Len()), accessLog["compressed_size"])
+				assert.Equal(float64(recorder.Body.Len()), accessLog["size"])
+			}
+		})
+	}
+}
+
+func TestCompressSkipped(t *testing.T) {
+	streaming := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":`)) // nolint:errcheck
+		w.(http.Flusher).Flush()
+		w.Write([]byte(largeJSON + "}")) // nolint:errcheck
+	}
+	sse := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: " + largeJSON + "\n\n")) // nolint:errcheck
+	}
+	image := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(bytes.Repeat([]byte{0}, 4096)) // nolint:errcheck
+	}
+
+	for name, testCase := range map[string]struct {
+		acceptEncoding string
+		handler        http.HandlerFunc
+	}{
+		"no accept encoding":   {"", serveJSON(largeJSON)},
+		"unsupported encoding": {"deflate", serveJSON(largeJSON)},
+		"below minimum size":   {"gzip", serveJSON(`{"data":"small"}`)},
+		"content type":         {"gzip", image},
+		"streaming response":   {"gzip", streaming},
+		"server-sent events":   {"gzip", sse},
+		"identity only (q=0)":  {"gzip;q=0", serveJSON(largeJSON)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			logs := &bytes.Buffer{}
+			handler := makeCompressHandler(logs, makeCompressionConfig(), testCase.handler)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", testCase.acceptEncoding)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(http.StatusOK, recorder.Code)
+			assert.Empty(recorder.Header().Get("Content-Encoding"))
+			assert.NotContains(logs.String(), "compressed_size")
+		})
+	}
+}
+
+func TestCompressDisabled(t *testing.T) {
+	compressionConfig := makeCompressionConfig()
+	compressionConfig.Enabled = false
+	handler := makeCompressHandler(&bytes.Buffer{}, compressionConfig, serveJSON(largeJSON))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeJSON, recorder.Body.String())
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/problem"
+)
+
+// CORS ...
+// Returns middleware which serves cross-origin requests from the origins allowed by
+// the given configuration. Preflight (OPTIONS) requests are answered automatically for
+// every route registered in the given router which accepts the requested method, so
+// routes do not need to accept the OPTIONS method themselves.
+//
+// Requests from origins which are not allowed are passed through without CORS headers,
+// which causes browser clients to reject the response.
+//
+// Note that this is registered around the router so that preflight requests are
+// answered before the router rejects them.
+func CORS(corsConfig *config.CORSConfig, router *mux.Router) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if !corsConfig.Enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			allowOrigin, ok := allowedOrigin(corsConfig, origin)
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				servePreflight(w, r, corsConfig, router, allowOrigin, ok)
+				return
+			}
+			if ok {
+				w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+				if corsConfig.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+				if len(corsConfig.ExposedHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsConfig.ExposedHeaders, ", "))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+func servePreflight(
+	w http.ResponseWriter,
+	r *http.Request,
+	corsConfig *config.CORSConfig,
+	router *mux.Router,
+	allowOrigin string,
+	originAllowed bool,
+) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	requestMethod := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	// Only answer preflights for registered routes which accept the requested method
+	routeReq := r.Clone(r.Context())
+	routeReq.Method = requestMethod
+	var match mux.RouteMatch
+	if !router.Match(routeReq, &match) || match.Route == nil {
+		problem.Write(w, r, problem.Newf(http.StatusNotFound, "No route matches %s %s", requestMethod, r.URL.Path))
+		return
+	}
+
+	if !originAllowed || !containsFold(corsConfig.AllowedMethods, requestMethod) {
+		hlog.FromRequest(r).Debug().Str("origin", r.Header.Get("Origin")).Str("request_method", requestMethod).Msg("Rejected CORS preflight request")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(corsConfig.AllowedMethods, ", "))
+	if containsFold(corsConfig.AllowedHeaders, "*") {
+		// Reflect the requested headers as * is not supported alongside credentials
+		if requestHeaders := r.Header.Get("Access-Control-Request-Headers"); requestHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", requestHeaders)
+		}
+	} else if len(corsConfig.AllowedHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsConfig.AllowedHeaders, ", "))
+	}
+	if corsConfig.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	if corsConfig.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", fmt.Sprint(int(corsConfig.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns the value of the Access-Control-Allow-Origin header for the given origin,
+// if the origin is allowed
+func allowedOrigin(corsConfig *config.CORSConfig, origin string) (string, bool) {
+	for _, pattern := range corsConfig.AllowedOrigins {
+		if pattern == "*" {
+			// Credentials are never allowed alongside a * origin (see config.Validate)
+			return "*", true
+		}
+		if matchOrigin(pattern, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// Matches an origin against an exact origin or a pattern with a single wildcard (e.g. https://*.example.com)
+func matchOrigin(pattern string, origin string) bool {
+	prefix, suffix, wildcard := strings.Cut(strings.ToLower(pattern), "*")
+	origin = strings.ToLower(origin)
+	if !wildcard {
+		return origin == prefix
+	}
+	return len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
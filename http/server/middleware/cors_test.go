@@ -0,0 +1,126 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+// Creates a router with a single GET route, wrapped in the CORS middleware
+func makeCORSRouter(corsConfig *config.CORSConfig) http.Handler {
+	router := mux.NewRouter()
+	router.Path("/users/{id}").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return middleware.CORS(corsConfig, router)(router)
+}
+
+func makeCORSConfig(allowedOrigins ...string) *config.CORSConfig {
+	return &config.CORSConfig{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"Request-Id"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+func newPreflightRequest(path string, origin string, method string) *http.Request {
+	req := httptest.NewRequest("OPTIONS", path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	return req
+}
+
+func TestCORSPreflight(t *testing.T) {
+	assert := assert.New(t)
+	router := makeCORSRouter(makeCORSConfig("https://app.example.com"))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, newPreflightRequest("/users/1", "https://app.example.com", "GET"))
+	assert.Equal(http.StatusNoContent, recorder.Code)
+	assert.Equal("https://app.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal("GET, POST", recorder.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal("Authorization, Content-Type", recorder.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal("600", recorder.Header().Get("Access-Control-Max-Age"))
+	assert.Empty(recorder.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(recorder.Header().Values("Vary"), "Origin")
+
+	// Preflights are only answered for registered routes which accept the requested method
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, newPreflightRequest("/unknown", "https://app.example.com", "GET"))
+	assert.Equal(http.StatusNotFound, recorder.Code)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, newPreflightRequest("/users/1", "https://app.example.com", "POST"))
+	assert.Equal(http.StatusNotFound, recorder.Code)
+}
+
+func TestCORSPreflightDisallowed(t *testing.T) {
+	assert := assert.New(t)
+	corsConfig := makeCORSConfig("https://app.example.com")
+	corsConfig.AllowedMethods = []string{"POST"}
+	router := makeCORSRouter(corsConfig)
+
+	for _, req := range []*http.Request{
+		newPreflightRequest("/users/1", "https://evil.example.com", "GET"),
+		newPreflightRequest("/users/1", "https://app.example.com", "GET"),
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.Empty(recorder.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		allowedOrigins []string
+		credentials    bool
+		origin         string
+		expected       string
+	}{
+		"exact":                {[]string{"https://app.example.com"}, false, "https://app.example.com", "https://app.example.com"},
+		"exact mismatch":       {[]string{"https://app.example.com"}, false, "https://api.example.com", ""},
+		"wildcard":             {[]string{"https://*.example.com"}, false, "https://api.example.com", "https://api.example.com"},
+		"wildcard mismatch":    {[]string{"https://*.example.com"}, false, "https://example.com", ""},
+		"wildcard scheme":      {[]string{"https://*.example.com"}, false, "http://api.example.com", ""},
+		"any":                  {[]string{"*"}, false, "https://other.org", "*"},
+		"wildcard credentials": {[]string{"https://*.example.com"}, true, "https://api.example.com", "https://api.example.com"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			corsConfig := makeCORSConfig(testCase.allowedOrigins...)
+			corsConfig.AllowCredentials = testCase.credentials
+			router := makeCORSRouter(corsConfig)
+
+			req := httptest.NewRequest("GET", "/users/1", nil)
+			req.Header.Set("Origin", testCase.origin)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			assert.Equal(http.StatusOK, recorder.Code)
+			assert.Equal(testCase.expected, recorder.Header().Get("Access-Control-Allow-Origin"))
+			if testCase.expected != "" {
+				assert.Equal("Request-Id", recorder.Header().Get("Access-Control-Expose-Headers"))
+			}
+			if testCase.credentials {
+				assert.Equal("true", recorder.Header().Get("Access-Control-Allow-Credentials"))
+			}
+		})
+	}
+}
+
+func TestCORSDisabled(t *testing.T) {
+	assert := assert.New(t)
+	router := makeCORSRouter(&config.CORSConfig{})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, newPreflightRequest("/users/1", "https://app.example.com", "GET"))
+	assert.Equal(http.StatusMethodNotAllowed, recorder.Code)
+	assert.Empty(recorder.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The route label used for requests which do not match any registered route.
+// The raw URL path is never used as a label value in order to bound cardinality.
+const unmatchedRoute = "unmatched"
+
+// The method label used for non-standard request methods, again to bound cardinality
+const otherMethod = "OTHER"
+
+// Metrics ...
+// RED (rate, errors, duration) metrics for HTTPServer requests, along with Go runtime
+// and process metrics. Metrics are registered in a dedicated registry rather than the
+// global Prometheus registry so that multiple servers can coexist in a single process.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight *prometheus.GaugeVec
+	responseSize     *prometheus.HistogramVec
+}
+
+// NewMetrics ...
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_server_requests_total",
+			Help: "Total number of HTTP requests by route, method and status class.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_server_request_duration_seconds",
+			Help:    "HTTP request latencies in seconds by route, method and status class.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		requestsInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_server_requests_in_flight",
+			Help: "Number of HTTP requests currently being served by route and method.",
+		}, []string{"route", "method"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_server_response_size_bytes",
+			Help:    "HTTP response body sizes in bytes by route, method and status class.",
+			Buckets: prometheus.ExponentialBuckets(100 /*start*/, 10 /*factor*/, 7 /*count*/),
+		}, []string{"route", "method", "status"}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.requestsInFlight,
+		m.responseSize,
+	)
+	return m
+}
+
+// Handler ...
+// Returns a handler which serves all metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registry ...
+// Returns the registry in which metrics are registered. Application specific
+// metrics may be registered here in order to be served alongside server metrics.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Middleware ...
+// Returns middleware which records metrics for each request served by the given router.
+// Requests are labelled by the path template of the route they match rather than the
+// raw URL in order to bound cardinality.
+//
+// Note that this is registered around the router (alongside the access log) so that
+// requests which are rejected by the router itself (e.g. 404s) are also recorded.
+func (m *Metrics) Middleware(router *mux.Router) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			routeLabel := matchedRouteTemplate(router, r)
+			methodLabel := methodLabel(r.Method)
+
+			inFlight := m.requestsInFlight.WithLabelValues(routeLabel, methodLabel)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			statusLabel := fmt.Sprintf("%dxx", sw.Status()/100)
+			m.requests.WithLabelValues(routeLabel, methodLabel, statusLabel).Inc()
+			m.requestDuration.WithLabelValues(routeLabel, methodLabel, statusLabel).Observe(time.Since(start).Seconds())
+			m.responseSize.WithLabelValues(routeLabel, methodLabel, statusLabel).Observe(float64(sw.size))
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+// Returns the path template of the route which the given request matches in the given router
+func matchedRouteTemplate(router *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+	if !router.Match(r, &match) || match.Route == nil {
+		return unmatchedRoute
+	}
+	pathTemplate, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return unmatchedRoute
+	}
+	return pathTemplate
+}
+
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return otherMethod
+	}
+}
@@ -0,0 +1,70 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+// Creates a router with a templated route, wrapped in the metrics middleware
+func makeMetricsRouter(metrics *middleware.Metrics) http.Handler {
+	router := mux.NewRouter()
+	router.Path("/users/{id}").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("user")) // nolint:errcheck
+	})
+	return metrics.Middleware(router)(router)
+}
+
+func TestMetricsRouteTemplate(t *testing.T) {
+	assert := assert.New(t)
+	metrics := middleware.NewMetrics()
+	router := makeMetricsRouter(metrics)
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Requests are labelled by route template rather than raw URL
+	count, err := testutil.GatherAndCount(metrics.Registry(), "http_server_requests_total")
+	if assert.NoError(err) {
+		assert.Equal(2, count)
+	}
+	count, err = testutil.GatherAndCount(metrics.Registry(), "http_server_request_duration_seconds", "http_server_response_size_bytes")
+	if assert.NoError(err) {
+		assert.Equal(4, count)
+	}
+	count, err = testutil.GatherAndCount(metrics.Registry(), "http_server_requests_in_flight")
+	if assert.NoError(err) {
+		assert.Equal(1, count)
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(recorder.Body.String(), `http_server_requests_total{method="GET",route="/users/{id}",status="2xx"} 2`)
+	assert.Contains(recorder.Body.String(), `http_server_requests_total{method="GET",route="/users/{id}",status="4xx"} 1`)
+	assert.Contains(recorder.Body.String(), `http_server_requests_in_flight{method="GET",route="/users/{id}"} 0`)
+}
+
+func TestMetricsUnmatchedRoute(t *testing.T) {
+	assert := assert.New(t)
+	metrics := middleware.NewMetrics()
+	router := makeMetricsRouter(metrics)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown/path", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PURGE", "/users/1", nil))
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(recorder.Body.String(), `http_server_requests_total{method="GET",route="unmatched",status="4xx"} 1`)
+	assert.Contains(recorder.Body.String(), `http_server_requests_total{method="OTHER",route="unmatched",status="4xx"} 1`)
+	assert.NotContains(recorder.Body.String(), "/unknown/path")
+}
@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/spals/starter-kit/http/server/auth"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/problem"
+)
+
+// Supported values for RateLimitConfig.KeyBy, other than the default (ip)
+const (
+	rateLimitKeyByAPIKey = "api_key"
+	rateLimitKeyByHeader = "header"
+)
+
+// RateLimit ...
+// Middleware which limits the rate of requests from each client with token buckets, responding
+// with a problem+json 429 once a limit is exceeded. Register after auth.Authenticate so that
+// clients may be identified by their credentials, and see route.Current for route limits.
+func RateLimit(rateLimitConfig *config.RateLimitConfig) mux.MiddlewareFunc {
+	// Note that mux applies middleware to each request, so the store must be shared
+	store := newBucketStore(rateLimitConfig.MaxKeys)
+	return func(next http.Handler) http.Handler {
+		if !rateLimitConfig.Enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var pathTemplate string
+			if route := mux.CurrentRoute(r); route != nil {
+				pathTemplate, _ = route.GetPathTemplate()
+			}
+			if containsString(rateLimitConfig.ExemptRoutes, pathTemplate) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var limits []scopedLimit
+			if rateLimitConfig.Rate > 0 {
+				limits = append(limits, scopedLimit{scope: "", rate: rateLimitConfig.Rate, burst: burst(rateLimitConfig.Rate, rateLimitConfig.Burst)})
+			}
+			if rate, ok := rateLimitConfig.RouteRates[pathTemplate]; ok && rate > 0 {
+				limits = append(limits, scopedLimit{scope: pathTemplate, rate: rate, burst: burst(rate, rateLimitConfig.RouteBursts[pathTemplate])})
+			}
+			if len(limits) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result := store.take(clientKeys(rateLimitConfig, r), limits, time.Now())
+			w.Header().Set("RateLimit-Limit", fmt.Sprint(result.limit))
+			w.Header().Set("RateLimit-Remaining", fmt.Sprint(result.remaining))
+			w.Header().Set("RateLimit-Reset", fmt.Sprint(ceilSeconds(result.reset)))
+			if result.allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			zerolog.Ctx(r.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+				return c.Bool("rate_limited", true)
+			})
+			w.Header().Set("Retry-After", fmt.Sprint(ceilSeconds(result.retryAfter)))
+			problem.Write(w, r, problem.New(http.StatusTooManyRequests, "Rate limit exceeded").With("route", pathTemplate))
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+// A token bucket limit applied within a scope (i.e. globally or to a single route)
+type scopedLimit struct {
+	scope string
+	rate  float64
+	burst int
+}
+
+// The outcome of taking a token for a request
+type takeResult struct {
+	allowed bool
+	// The burst, remaining tokens and time until the bucket is full for the most restrictive limit
+	limit     int
+	remaining int
+	reset     time.Duration
+	// The time until the request would be allowed, if it was rejected
+	retryAfter time.Duration
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// An in-memory store of token buckets with a bounded size. The least recently used
+// buckets are evicted once the store is full. An evicted bucket starts full if its
+// client returns.
+type bucketStore struct {
+	mu       sync.Mutex
+	capacity int
+	buckets  map[string]*list.Element
+	// Buckets ordered from most to least recently used
+	lru *list.List
+}
+
+func newBucketStore(capacity int) *bucketStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &bucketStore{capacity: capacity, buckets: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Takes a token from the bucket of each given limit for each of the given clients. A
+// token is only taken if every bucket has a token available.
+func (s *bucketStore) take(clients []string, limits []scopedLimit, now time.Time) takeResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type limitedBucket struct {
+		*bucket
+		limit scopedLimit
+	}
+	var buckets []limitedBucket
+	allowed := true
+	for _, client := range clients {
+		for _, limit := range limits {
+			b := s.get(limit.scope+"|"+client, limit, now)
+			b.tokens = math.Min(float64(limit.burst), b.tokens+now.Sub(b.last).Seconds()*limit.rate)
+			b.last = now
+			buckets = append(buckets, limitedBucket{bucket: b, limit: limit})
+			if b.tokens < 1 {
+				allowed = false
+			}
+		}
+		// Do not create buckets for the remaining clients of a rejected request, so that
+		// rejected clients cannot evict the buckets of other clients
+		if !allowed {
+			break
+		}
+	}
+
+	result := takeResult{allowed: allowed, remaining: math.MaxInt}
+	for _, b := range buckets {
+		limit := b.limit
+		if allowed {
+			b.tokens--
+		} else if b.tokens < 1 {
+			retryAfter := time.Duration((1 - b.tokens) / limit.rate * float64(time.Second))
+			if retryAfter > result.retryAfter {
+				result.retryAfter = retryAfter
+			}
+		}
+		if remaining := int(b.tokens); remaining < result.remaining {
+			result.limit = limit.burst
+			result.remaining = remaining
+			result.reset = time.Duration((float64(limit.burst) - b.tokens) / limit.rate * float64(time.Second))
+		}
+	}
+	return result
+}
+
+// Returns the bucket with the given key, creating a full bucket (and evicting the
+// least recently used bucket if necessary) if it does not exist
+func (s *bucketStore) get(key string, limit scopedLimit, now time.Time) *bucket {
+	if element, ok := s.buckets[key]; ok {
+		s.lru.MoveToFront(element)
+		return element.Value.(*bucket)
+	}
+
+	if s.lru.Len() >= s.capacity {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.buckets, oldest.Value.(*bucket).key)
+	}
+	b := &bucket{key: key, tokens: float64(limit.burst), last: now}
+	s.buckets[key] = s.lru.PushFront(b)
+	return b
+}
+
+// Returns the keys which identify the client of the given request, starting with the
+// most trusted key
+func clientKeys(rateLimitConfig *config.RateLimitConfig, r *http.Request) []string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ipKey := "ip:" + host
+
+	switch rateLimitConfig.KeyBy {
+	case rateLimitKeyByAPIKey:
+		if claims, ok := auth.ClaimsFromContext(r.Context()); ok {
+			if claims.KeyID != "" {
+				return []string{"api_key:" + claims.KeyID}
+			}
+			return []string{"subject:" + claims.Subject}
+		}
+	case rateLimitKeyByHeader:
+		if value := r.Header.Get(rateLimitConfig.KeyHeader); rateLimitConfig.KeyHeader != "" && value != "" {
+			// Unverified header values are always limited by IP as well
+			return []string{ipKey, "header:" + value}
+		}
+	}
+	return []string{ipKey}
+}
+
+// Returns the given burst, or the given rate rounded up if no burst is configured
+func burst(rate float64, burst int) int {
+	if burst > 0 {
+		return burst
+	}
+	return int(math.Max(1, math.Ceil(rate)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/spals/starter-kit/http/server/auth"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+// Creates a router with login, users and health check routes, wrapped in the rate limit
+// middleware. Requests with an X-Test-Key header are authenticated with that API key ID.
+func makeRateLimitRouter(rateLimitConfig *config.RateLimitConfig) http.Handler {
+	router := mux.NewRouter()
+	for _, path := range []string{"/login", "/users/{id}", "/live"} {
+		router.Path(path).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if keyID := r.Header.Get("X-Test-Key"); keyID != "" {
+				r = r.WithContext(auth.WithClaims(r.Context(), &auth.Claims{Subject: "principal", KeyID: keyID}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.Use(middleware.RateLimit(rateLimitConfig))
+	return router
+}
+
+func makeRateLimitConfig() *config.RateLimitConfig {
+	return &config.RateLimitConfig{KeyBy: "ip", MaxKeys: 100, ExemptRoutes: []string{"/live"}}
+}
+
+func serveFrom(handler http.Handler, remoteAddr string, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = remoteAddr
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRateLimitGlobal(t *testing.T) {
+	assert := assert.New(t)
+	rateLimitConfig := makeRateLimitConfig()
+	rateLimitConfig.Rate = 0.1
+	rateLimitConfig.Burst = 2
+	router := makeRateLimitRouter(rateLimitConfig)
+
+	first := serveFrom(router, "10.0.0.1:1234", "/users/1")
+	assert.Equal(http.StatusOK, first.Code)
+	assert.Equal("2", first.Header().Get("RateLimit-Limit"))
+	assert.Equal("1", first.Header().Get("RateLimit-Remaining"))
+	assert.Equal("10", first.Header().Get("RateLimit-Reset"))
+	// The global limit applies across routes
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.1:1234", "/login").Code)
+
+	rejected := serveFrom(router, "10.0.0.1:5678", "/users/2")
+	assert.Equal(http.StatusTooManyRequests, rejected.Code)
+	assert.Equal("10", rejected.Header().Get("Retry-After"))
+	assert.Equal("0", rejected.Header().Get("RateLimit-Remaining"))
+	var body map[string]interface{}
+	if assert.NoError(json.Unmarshal(rejected.Body.Bytes(), &body)) {
+		assert.Equal(float64(429), body["status"])
+		assert.Equal("/users/{id}", body["route"])
+	}
+
+	// Other clients have their own buckets
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.2:1234", "/users/1").Code)
+}
+
+func TestRateLimitRoute(t *testing.T) {
+	assert := assert.New(t)
+	rateLimitConfig := makeRateLimitConfig()
+	rateLimitConfig.Rate = 100
+	rateLimitConfig.RouteRates = map[string]float64{"/login": 0.5}
+	router := makeRateLimitRouter(rateLimitConfig)
+
+	login := serveFrom(router, "10.0.0.1:1234", "/login")
+	assert.Equal(http.StatusOK, login.Code)
+	// The most restrictive limit is reported
+	assert.Equal("1", login.Header().Get("RateLimit-Limit"))
+	assert.Equal("0", login.Header().Get("RateLimit-Remaining"))
+
+	rejected := serveFrom(router, "10.0.0.1:1234", "/login")
+	assert.Equal(http.StatusTooManyRequests, rejected.Code)
+	assert.Equal("2", rejected.Header().Get("Retry-After"))
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.1:1234", "/users/1").Code)
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	assert := assert.New(t)
+	rateLimitConfig := makeRateLimitConfig()
+	rateLimitConfig.Rate = 0.1
+	rateLimitConfig.KeyBy = "api_key"
+	router := makeRateLimitRouter(rateLimitConfig)
+
+	// Authenticated clients are identified by their verified API key rather than IP
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.1:1234", "/login", "X-Test-Key", "key-1").Code)
+	assert.Equal(http.StatusTooManyRequests, serveFrom(router, "10.0.0.2:1234", "/login", "X-Test-Key", "key-1").Code)
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.1:1234", "/login", "X-Test-Key", "key-2").Code)
+	// Unverified API keys are ignored, so unauthenticated clients are identified by IP
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.1:1234", "/login", "X-API-Key", "key-3").Code)
+	assert.Equal(http.StatusTooManyRequests, serveFrom(router, "10.0.0.1:1234", "/login", "X-API-Key", "key-4").Code)
+}
+
+func TestRateLimitHeaderKeys(t *testing.T) {
+	assert := assert.New(t)
+	rateLimitConfig := makeRateLimitConfig()
+	rateLimitConfig.Rate = 0.1
+	rateLimitConfig.KeyBy = "header"
+	rateLimitConfig.KeyHeader = "X-Client-ID"
+	rateLimitConfig.MaxKeys = 5
+	router := makeRateLimitRouter(rateLimitConfig)
+
+	// Clients are identified by header, but are also limited by IP
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.1:1234", "/login", "X-Client-ID", "client-1").Code)
+	assert.Equal(http.StatusTooManyRequests, serveFrom(router, "10.0.0.2:1234", "/login", "X-Client-ID", "client-1").Code)
+	assert.Equal(http.StatusTooManyRequests, serveFrom(router, "10.0.0.1:1234", "/login", "X-Client-ID", "client-2").Code)
+
+	// A client which changes the header on each request is limited by IP, and its rejected
+	// requests do not evict the buckets of other clients (which would start full)
+	for i := 0; i < 5; i++ {
+		expected := http.StatusTooManyRequests
+		if i == 0 {
+			expected = http.StatusOK
+		}
+		assert.Equal(expected, serveFrom(router, "10.0.0.9:1234", "/login", "X-Client-ID", fmt.Sprint("rotated-", i)).Code)
+	}
+	assert.Equal(http.StatusTooManyRequests, serveFrom(router, "10.0.0.3:1234", "/login", "X-Client-ID", "client-1").Code)
+}
+
+func TestRateLimitExemptRoutes(t *testing.T) {
+	assert := assert.New(t)
+	rateLimitConfig := makeRateLimitConfig()
+	rateLimitConfig.Rate = 0.1
+	router := makeRateLimitRouter(rateLimitConfig)
+
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.1:1234", "/login").Code)
+	for i := 0; i < 5; i++ {
+		recorder := serveFrom(router, "10.0.0.1:1234", "/live")
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Empty(recorder.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitEviction(t *testing.T) {
+	assert := assert.New(t)
+	rateLimitConfig := makeRateLimitConfig()
+	rateLimitConfig.Rate = 0.1
+	rateLimitConfig.MaxKeys = 1
+	router := makeRateLimitRouter(rateLimitConfig)
+
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.1:1234", "/login").Code)
+	assert.Equal(http.StatusTooManyRequests, serveFrom(router, "10.0.0.1:1234", "/login").Code)
+	// A new client evicts the least recently used bucket, which starts full when its client returns
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.2:1234", "/login").Code)
+	assert.Equal(http.StatusOK, serveFrom(router, "10.0.0.1:1234", "/login").Code)
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	router := makeRateLimitRouter(makeRateLimitConfig())
+	for i := 0; i < 10; i++ {
+		recorder := serveFrom(router, "10.0.0.1:1234", "/login")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("RateLimit-Limit"))
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/spals/starter-kit/http/server/problem"
+)
+
+// PanicCounter ...
+// Counts the number of panics recovered from request handlers. This allows
+// health checks to react to panicking handlers.
+type PanicCounter struct {
+	count int64
+}
+
+// NewPanicCounter ...
+func NewPanicCounter() *PanicCounter {
+	return &PanicCounter{}
+}
+
+// Count ...
+// Returns the number of panics recovered so far
+func (c *PanicCounter) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+// A panic recovered from a handler running on a different goroutine, along with its original stack
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// Recovery ...
+// Middleware which recovers from panics in request handlers. The panic value, stack,
+// request ID and route are logged through the request logger, a problem+json 500
+// response is written (if the handler has not already written a response) and the
+// given panic counter is incremented.
+//
+// Panics with http.ErrAbortHandler are re-raised in order to abort the response.
+//
+// When registered as the first mux middleware, the matched route is known and panics
+// from any other mux middleware are recovered. It may also wrap handlers outside of
+// the router, in which case panics are logged without a route.
+func Recovery(counter *PanicCounter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+
+				value, stack := p, debug.Stack()
+				if hp, ok := p.(*handlerPanic); ok {
+					value, stack = hp.value, hp.stack
+				}
+				if value == http.ErrAbortHandler {
+					panic(value)
+				}
+
+				var pathTemplate string
+				if route := mux.CurrentRoute(r); route != nil {
+					pathTemplate, _ = route.GetPathTemplate()
+				}
+				counter.record(hlog.FromRequest(r), value, stack, pathTemplate)
+
+				if sw.wroteHeader {
+					// It is too late to write an error response, so abort the response instead
+					panic(http.ErrAbortHandler)
+				}
+				problem.Write(w, r, problem.New(http.StatusInternalServerError, "").With("route", pathTemplate))
+			}()
+
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+// Counts and logs a recovered panic
+func (c *PanicCounter) record(logger *zerolog.Logger, value interface{}, stack []byte, pathTemplate string) {
+	atomic.AddInt64(&c.count, 1)
+	logger.Error().
+		Str("panic", fmt.Sprint(value)).
+		Str("stack", string(stack)).
+		Str("route", pathTemplate).
+		Msg("Recovered from HTTP handler panic")
+}
@@ -0,0 +1,138 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spals/starter-kit/http/server/internal/servertest"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/spals/starter-kit/http/server/route"
+	"github.com/stretchr/testify/assert"
+)
+
+// Creates a router with panicking routes, wrapped in a request logger and access log which write
+// to the given writer
+func makeRecoveryRouter(logs io.Writer, counter *middleware.PanicCounter) http.Handler {
+	router := servertest.Router(route.Group{
+		Routes: []route.Route{
+			{Path: "/panic/{id}", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			})},
+			// Panics from handlers running under a deadline are raised on a different goroutine
+			{Path: "/slow-panic", Timeout: time.Second, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic("slow boom")
+			})},
+			{Path: "/ok", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})},
+		},
+	}, middleware.Recovery(counter), middleware.Timeout(0 /*defaultTimeout*/, counter))
+	return servertest.Handler(logs, router)
+}
+
+func TestRecoveryPanic(t *testing.T) {
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	counter := middleware.NewPanicCounter()
+	router := makeRecoveryRouter(logs, counter)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/panic/1", nil))
+	assert.Equal(http.StatusInternalServerError, recorder.Code)
+	assert.Equal("application/problem+json", recorder.Header().Get("Content-Type"))
+	assert.Equal(int64(1), counter.Count())
+
+	var body map[string]interface{}
+	if assert.NoError(json.Unmarshal(recorder.Body.Bytes(), &body)) {
+		assert.Equal(float64(500), body["status"])
+		assert.Equal("Internal Server Error", body["title"])
+		assert.Equal("/panic/1", body["instance"])
+		assert.Equal("/panic/{id}", body["route"])
+		assert.Equal(recorder.Header().Get("Request-Id"), body["req_id"])
+	}
+
+	// The crash log precedes the access log
+	if entries := servertest.LogEntries(t, logs.Bytes()); assert.NotEmpty(entries) {
+		crashLog := entries[0]
+		assert.Equal("error", crashLog["level"])
+		assert.Equal("boom", crashLog["panic"])
+		assert.Equal("/panic/{id}", crashLog["route"])
+		assert.Equal(recorder.Header().Get("Request-Id"), crashLog["req_id"])
+		assert.Contains(crashLog["stack"], "recovery_test.go")
+	}
+}
+
+func TestRecoveryPanicWithTimeout(t *testing.T) {
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	counter := middleware.NewPanicCounter()
+	router := makeRecoveryRouter(logs, counter)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/slow-panic", nil))
+	assert.Equal(http.StatusInternalServerError, recorder.Code)
+	assert.Equal(int64(1), counter.Count())
+
+	// The crash log precedes the access log
+	if entries := servertest.LogEntries(t, logs.Bytes()); assert.NotEmpty(entries) {
+		crashLog := entries[0]
+		assert.Equal("slow boom", crashLog["panic"])
+		// The stack is that of the handler goroutine rather than the request goroutine
+		assert.Contains(crashLog["stack"], "recovery_test.go")
+	}
+}
+
+func TestRecoveryNoPanic(t *testing.T) {
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	counter := middleware.NewPanicCounter()
+	router := makeRecoveryRouter(logs, counter)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/ok", nil))
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal(int64(0), counter.Count())
+	assert.NotContains(logs.String(), "panic")
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/abort").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	counter := middleware.NewPanicCounter()
+	router.Use(middleware.Recovery(counter))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+	})
+	assert.Equal(t, int64(0), counter.Count())
+}
+
+func TestRecoveryOutsideRouter(t *testing.T) {
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	counter := middleware.NewPanicCounter()
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("middleware boom")
+	})
+	handler := servertest.Handler(logs, middleware.Recovery(counter)(panicking))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/anything", nil))
+	assert.Equal(http.StatusInternalServerError, recorder.Code)
+	assert.Equal(int64(1), counter.Count())
+
+	// The crash log precedes the access log
+	if entries := servertest.LogEntries(t, logs.Bytes()); assert.NotEmpty(entries) {
+		crashLog := entries[0]
+		assert.Equal("middleware boom", crashLog["panic"])
+		assert.Equal("", crashLog["route"])
+	}
+}
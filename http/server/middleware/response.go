@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// A response writer which records the status and size of the response written through it
+type statusWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int
+	size        int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.wroteHeader = true
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	n, err := sw.ResponseWriter.Write(p)
+	sw.size += n
+	return n, err
+}
+
+// Flush ...
+// Supports streaming handlers
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		if !sw.wroteHeader {
+			sw.WriteHeader(http.StatusOK)
+		}
+		flusher.Flush()
+	}
+}
+
+// Unwrap ...
+// Allows http.ResponseController to reach the underlying response writer
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Returns the status written through the writer, defaulting to 200 if nothing was written
+func (sw *statusWriter) Status() int {
+	if !sw.wroteHeader {
+		return http.StatusOK
+	}
+	return sw.status
+}
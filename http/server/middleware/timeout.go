@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/spals/starter-kit/http/server/problem"
+	"github.com/spals/starter-kit/http/server/route"
+)
+
+// Timeout ...
+// Middleware which applies the default or route deadline (see route.Route and route.Current)
+// to each request, responding with a problem+json 504 once it passes
+func Timeout(defaultTimeout time.Duration, counter *PanicCounter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := defaultTimeout
+			if registeredRoute, _ := route.Current(r); registeredRoute.Timeout != 0 {
+				timeout = registeredRoute.Timeout
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var pathTemplate string
+			if muxRoute := mux.CurrentRoute(r); muxRoute != nil {
+				pathTemplate, _ = muxRoute.GetPathTemplate()
+			}
+			serveWithTimeout(w, r, next, timeout, pathTemplate, counter)
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+func serveWithTimeout(w http.ResponseWriter, r *http.Request, next http.Handler, timeout time.Duration, pathTemplate string, counter *PanicCounter) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	// Give the handler its own copy of the request logger so that the request
+	// logger can be safely updated if the handler is abandoned
+	reqLogger := hlog.FromRequest(r)
+	handlerLogger := reqLogger.With().Logger()
+	ctx = handlerLogger.WithContext(ctx)
+
+	tw := &timeoutWriter{w: w, h: make(http.Header)}
+	done := make(chan struct{})
+	panicked := make(chan *handlerPanic, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				// Capture the stack of the handler goroutine as it is lost when re-panicking
+				panicked <- &handlerPanic{value: p, stack: debug.Stack()}
+			}
+		}()
+		next.ServeHTTP(tw, r.WithContext(ctx))
+		close(done)
+	}()
+
+	select {
+	case p := <-panicked:
+		// Re-panic on the request goroutine so that it is handled as any other panic
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		if !tw.flushed {
+			tw.writeHeaderTo(w)
+		}
+		w.Write(tw.wbuf.Bytes()) // nolint:errcheck
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.timedOut = true
+
+		reqLogger.UpdateContext(func(c zerolog.Context) zerolog.Context {
+			return c.Bool("timed_out", true).Dur("timeout", timeout)
+		})
+		// The abandoned handler may still panic, which must not go unnoticed
+		go func() {
+			select {
+			case p := <-panicked:
+				if p.value != http.ErrAbortHandler {
+					logger := handlerLogger.With().Bool("timed_out", true).Logger()
+					counter.record(&logger, p.value, p.stack, pathTemplate)
+				}
+			case <-done:
+			}
+		}()
+
+		if tw.flushed {
+			// It is too late to write an error response, so the response is cut short
+			return
+		}
+		problem.Write(w, r, problem.Newf(http.StatusGatewayTimeout, "Request exceeded the route timeout of %s", timeout).
+			With("route", pathTemplate).
+			With("timeout", timeout.String()))
+	}
+}
+
+// A buffered response writer which discards the response once the request has timed out
+// See net/http.TimeoutHandler
+type timeoutWriter struct {
+	w    http.ResponseWriter
+	h    http.Header
+	wbuf bytes.Buffer
+
+	mu          sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+	code        int
+	// Set once the buffered response has been flushed to the underlying response writer
+	flushed bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.wbuf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.code = code
+}
+
+// Flush ...
+// Supports streaming handlers by writing the buffered response through to the underlying
+// response writer, as long as the deadline has not passed
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.flushed {
+		tw.writeHeaderTo(tw.w)
+		tw.flushed = true
+	}
+	tw.w.Write(tw.wbuf.Bytes()) // nolint:errcheck
+	tw.wbuf.Reset()
+	if flusher, ok := tw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Writes the buffered header to the given response writer
+func (tw *timeoutWriter) writeHeaderTo(w http.ResponseWriter) {
+	dst := w.Header()
+	for k, vv := range tw.h {
+		dst[k] = vv
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	w.WriteHeader(tw.code)
+}
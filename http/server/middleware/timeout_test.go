@@ -0,0 +1,158 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spals/starter-kit/http/server/internal/servertest"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/spals/starter-kit/http/server/route"
+	"github.com/stretchr/testify/assert"
+)
+
+// Creates a router with slow, fast, streaming and late panicking routes, wrapped in a request logger and
+// access log which write to the given writer
+func makeTimeoutRouter(logs io.Writer, defaultTimeout time.Duration, counter *middleware.PanicCounter) http.Handler {
+	slowHandler := func(delay time.Duration) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(delay):
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	router := servertest.Router(route.Group{
+		Routes: []route.Route{
+			{Path: "/slow", Handler: slowHandler(time.Second)},
+			{Path: "/slow-route", Handler: slowHandler(time.Second), Timeout: 10 * time.Millisecond},
+			{Path: "/unbounded", Handler: slowHandler(50 * time.Millisecond), Timeout: -1},
+			{Path: "/fast", Timeout: time.Second, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Fast", "true")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("fast")) // nolint:errcheck
+			})},
+			{Path: "/stream", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("data: first\n\n")) // nolint:errcheck
+				w.(http.Flusher).Flush()
+				// Wait for the deadline to pass
+				<-r.Context().Done()
+				w.Write([]byte("data: second\n\n")) // nolint:errcheck
+			})},
+			{Path: "/late-panic", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				panic("late boom")
+			})},
+		},
+	}, middleware.Timeout(defaultTimeout, counter))
+	return servertest.Handler(logs, router)
+}
+
+func TestTimeoutExceeded(t *testing.T) {
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	router := makeTimeoutRouter(logs, 0 /*defaultTimeout*/, middleware.NewPanicCounter())
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/slow-route", nil))
+	assert.Equal(http.StatusGatewayTimeout, recorder.Code)
+	assert.Equal("application/problem+json", recorder.Header().Get("Content-Type"))
+
+	var body map[string]interface{}
+	if assert.NoError(json.Unmarshal(recorder.Body.Bytes(), &body)) {
+		assert.Equal(float64(504), body["status"])
+		assert.Equal("/slow-route", body["route"])
+		assert.Equal("10ms", body["timeout"])
+	}
+
+	var accessLog map[string]interface{}
+	if assert.NoError(json.Unmarshal(logs.Bytes(), &accessLog)) {
+		assert.Equal(true, accessLog["timed_out"])
+		assert.Equal(float64(504), accessLog["status"])
+	}
+}
+
+func TestTimeoutNotExceeded(t *testing.T) {
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	router := makeTimeoutRouter(logs, time.Second /*defaultTimeout*/, middleware.NewPanicCounter())
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/fast", nil))
+	assert.Equal(http.StatusCreated, recorder.Code)
+	assert.Equal("true", recorder.Header().Get("X-Fast"))
+	assert.Equal("fast", recorder.Body.String())
+	assert.NotContains(logs.String(), "timed_out")
+}
+
+func TestTimeoutDefault(t *testing.T) {
+	assert := assert.New(t)
+	router := makeTimeoutRouter(&bytes.Buffer{}, 10*time.Millisecond /*defaultTimeout*/, middleware.NewPanicCounter())
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/slow", nil))
+	assert.Equal(http.StatusGatewayTimeout, recorder.Code)
+
+	// Route timeouts override the default, and a negative timeout removes the deadline
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/fast", nil))
+	assert.Equal(http.StatusCreated, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/unbounded", nil))
+	assert.Equal(http.StatusOK, recorder.Code)
+}
+
+func TestTimeoutStreaming(t *testing.T) {
+	assert := assert.New(t)
+	router := makeTimeoutRouter(&bytes.Buffer{}, 10*time.Millisecond /*defaultTimeout*/, middleware.NewPanicCounter())
+
+	// Flushed responses are written through, and are cut short (rather than replaced) once the deadline passes
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/stream", nil))
+	assert.True(recorder.Flushed)
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal("text/event-stream", recorder.Header().Get("Content-Type"))
+	assert.Equal("data: first\n\n", recorder.Body.String())
+}
+
+func TestTimeoutLatePanic(t *testing.T) {
+	assert := assert.New(t)
+	logs := &syncBuffer{}
+	counter := middleware.NewPanicCounter()
+	router := makeTimeoutRouter(logs, 10*time.Millisecond /*defaultTimeout*/, counter)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/late-panic", nil))
+	assert.Equal(http.StatusGatewayTimeout, recorder.Code)
+
+	// Panics from abandoned handlers are counted and logged
+	assert.Eventually(func() bool { return counter.Count() == 1 }, time.Second /*waitFor*/, 5*time.Millisecond /*tick*/)
+	assert.Eventually(func() bool { return strings.Contains(logs.String(), "late boom") }, time.Second /*waitFor*/, 5*time.Millisecond /*tick*/)
+}
+
+// A buffer which may be written by abandoned handlers while it is read
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
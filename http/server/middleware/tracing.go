@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/spals/starter-kit/http/server/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// The instrumentation scope name used for server spans
+const tracerName = "github.com/spals/starter-kit/http/server"
+
+// Available exporters for TracingConfig.Exporter
+const (
+	tracingExporterNone   = "none"
+	tracingExporterStdout = "stdout"
+	tracingExporterOTLP   = "otlp"
+)
+
+// Tracing ...
+// OpenTelemetry tracing for HTTPServer requests. Incoming W3C trace context
+// (traceparent and tracestate) is extracted from each request and a server span
+// is started as its child.
+//
+// The tracer provider is dedicated to HTTPServer rather than registered globally.
+// Handlers may start child spans from the request context, e.g.
+//
+//	trace.SpanFromContext(r.Context()).TracerProvider().Tracer("my-handler")
+type Tracing struct {
+	// The SDK tracer provider, if an exporter is configured
+	provider   *sdktrace.TracerProvider
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewTracing ...
+func NewTracing(config *config.HTTPServerConfig) (*Tracing, error) {
+	t := &Tracing{
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+	if !config.TracingConfig.Enabled() {
+		// Trace context is still propagated, so that logs can be correlated with the caller's trace
+		t.tracer = noop.NewTracerProvider().Tracer(tracerName)
+		return t, nil
+	}
+
+	exporter, err := makeSpanExporter(config.TracingConfig)
+	if err != nil {
+		return nil, fmt.Errorf("HTTPServer tracing configuration failure: %w", err)
+	}
+	t.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TracingConfig.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(config.TracingConfig.ServiceName))),
+	)
+	t.tracer = t.provider.Tracer(tracerName)
+	return t, nil
+}
+
+// Propagator ...
+// Returns the propagator used to extract trace context from requests
+func (t *Tracing) Propagator() propagation.TextMapPropagator {
+	return t.propagator
+}
+
+// Shutdown ...
+// Flushes any buffered spans to the exporter
+func (t *Tracing) Shutdown(ctx context.Context) error {
+	if t.provider == nil {
+		return nil
+	}
+	return t.provider.Shutdown(ctx)
+}
+
+// Middleware ...
+// Returns middleware which starts a server span for each request served by the given
+// router. Spans are named after the path template of the route the request matches.
+// The trace and span IDs are added to the request logger so that they appear in every
+// request log line.
+//
+// Note that this is registered around the router (alongside the access log) so that
+// requests which are rejected by the router itself (e.g. 404s) are also traced.
+func (t *Tracing) Middleware(router *mux.Router) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			attrs := []attribute.KeyValue{
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.NetworkProtocolVersion(fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor)),
+			}
+			spanName := r.Method
+			if pathTemplate := matchedRouteTemplate(router, r); pathTemplate != unmatchedRoute {
+				spanName = pathTemplate
+				attrs = append(attrs, semconv.HTTPRoute(pathTemplate))
+			}
+			ctx, span := t.tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+			defer span.End()
+
+			if spanContext := span.SpanContext(); spanContext.IsValid() {
+				zerolog.Ctx(ctx).UpdateContext(func(c zerolog.Context) zerolog.Context {
+					return c.Str("trace_id", spanContext.TraceID().String()).Str("span_id", spanContext.SpanID().String())
+				})
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(sw.Status()))
+			if sw.Status() >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(sw.Status()))
+			}
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+func makeSpanExporter(tracingConfig *config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch tracingConfig.Exporter {
+	case tracingExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case tracingExporterOTLP:
+		return otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(tracingConfig.OTLPEndpoint))
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter (%s). Available exporters are (%s|%s|%s)",
+			tracingConfig.Exporter, tracingExporterNone, tracingExporterStdout, tracingExporterOTLP)
+	}
+}
@@ -0,0 +1,156 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog/hlog"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server/config"
+	"github.com/spals/starter-kit/http/server/internal/servertest"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/spals/starter-kit/http/server/route"
+	"github.com/stretchr/testify/assert"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+	testTraceparent = "00-" + testTraceID + "-" + testParentID + "-01"
+)
+
+// Creates a router with a templated route, wrapped in a request logger and access log which write
+// to the given writer
+func makeTracingRouter(logs io.Writer, tracing *middleware.Tracing) http.Handler {
+	router := servertest.Router(route.Group{
+		Routes: []route.Route{
+			{Path: "/users/{id}", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hlog.FromRequest(r).Info().Msg("Handling request")
+				w.WriteHeader(http.StatusOK)
+			})},
+		},
+	})
+	return servertest.Handler(logs, tracing.Middleware(router)(router))
+}
+
+func makeTracing(t *testing.T, configMap map[string]string) *middleware.Tracing {
+	configMap["LOG_LEVEL"] = "trace"
+	httpServerConfig, err := config.NewHTTPServerConfig(envconfig.MapLookuper(configMap))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	tracing, err := middleware.NewTracing(httpServerConfig)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return tracing
+}
+
+func TestTracingPropagation(t *testing.T) {
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	router := makeTracingRouter(logs, makeTracing(t, map[string]string{"TRACING_EXPORTER": "stdout"}))
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	req.Header.Set("tracestate", "vendor=value")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Every request log line is annotated with the trace ID of the caller and the ID of the server span
+	lines := bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n"))
+	if assert.Len(lines, 2) {
+		for _, line := range lines {
+			var logLine map[string]interface{}
+			if assert.NoError(json.Unmarshal(line, &logLine)) {
+				assert.Equal(testTraceID, logLine["trace_id"])
+				assert.NotEmpty(logLine["span_id"])
+				assert.NotEqual(testParentID, logLine["span_id"])
+			}
+		}
+	}
+}
+
+func TestTracingDisabledPropagation(t *testing.T) {
+	assert := assert.New(t)
+	logs := &bytes.Buffer{}
+	router := makeTracingRouter(logs, makeTracing(t, map[string]string{}))
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Without an exporter, the caller's trace context is still logged
+	var accessLog map[string]interface{}
+	lines := bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n"))
+	if assert.NoError(json.Unmarshal(lines[len(lines)-1], &accessLog)) {
+		assert.Equal(testTraceID, accessLog["trace_id"])
+		assert.Equal(testParentID, accessLog["span_id"])
+	}
+
+	// Without an exporter or a caller's trace context, there is nothing to log
+	logs.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+	assert.NotContains(logs.String(), "trace_id")
+}
+
+func TestTracingOTLPExport(t *testing.T) {
+	assert := assert.New(t)
+	// A stub collector which records exported spans
+	var mu sync.Mutex
+	var spans []string
+	var parentSpanIDs [][]byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var exportReq coltracepb.ExportTraceServiceRequest
+		if assert.NoError(proto.Unmarshal(body, &exportReq)) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, resourceSpans := range exportReq.ResourceSpans {
+				for _, scopeSpans := range resourceSpans.ScopeSpans {
+					for _, span := range scopeSpans.Spans {
+						spans = append(spans, span.Name)
+						parentSpanIDs = append(parentSpanIDs, span.ParentSpanId)
+					}
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	tracing := makeTracing(t, map[string]string{"TRACING_EXPORTER": "otlp", "TRACING_OTLP_ENDPOINT": collector.URL})
+	router := makeTracingRouter(&bytes.Buffer{}, tracing)
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown", nil))
+	// Flush spans to the collector
+	assert.NoError(tracing.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal([]string{"/users/{id}", "GET"}, spans)
+	if assert.Len(parentSpanIDs, 2) {
+		assert.Equal(testParentID, hex.EncodeToString(parentSpanIDs[0]))
+	}
+}
+
+func TestTracingInvalidExporter(t *testing.T) {
+	configMap := map[string]string{"LOG_LEVEL": "trace", "TRACING_EXPORTER": "jaeger"}
+	httpServerConfig, err := config.NewHTTPServerConfig(envconfig.MapLookuper(configMap))
+	if assert.NoError(t, err) {
+		_, err = middleware.NewTracing(httpServerConfig)
+		assert.Error(t, err)
+	}
+}
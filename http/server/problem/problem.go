@@ -0,0 +1,209 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// The media type of problem responses
+const ContentType = "application/problem+json"
+
+// The problem type used when a problem has no more specific type
+const defaultType = "about:blank"
+
+// Problem ...
+// An RFC 7807 problem details object, written as an application/problem+json response.
+// Problems implement error, so handlers may return them directly (see HandlerFunc).
+// See https://datatracker.ietf.org/doc/html/rfc7807
+type Problem struct {
+	// A URI reference which identifies the problem type. Defaults to about:blank.
+	Type string
+	// A short summary of the problem type. Defaults to the status text.
+	Title  string
+	Status int
+	// An explanation specific to this occurrence of the problem
+	Detail string
+	// A URI reference which identifies this occurrence of the problem. Defaults to the request path.
+	Instance string
+	// Additional members of the problem (e.g. the matched route)
+	Extensions map[string]interface{}
+
+	// The underlying error, which is never written in the response
+	cause error
+}
+
+// Error ...
+// An error which maps to a problem. Handlers may define their own error types which
+// implement this interface to control how they are written (see WriteError).
+type Error interface {
+	error
+	Problem() *Problem
+}
+
+// New ...
+// Creates a problem with the given status and detail
+func New(status int, detail string) *Problem {
+	return &Problem{Type: defaultType, Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// Newf ...
+// Creates a problem with the given status and formatted detail
+func Newf(status int, format string, args ...interface{}) *Problem {
+	return New(status, fmt.Sprintf(format, args...))
+}
+
+// BadRequest ...
+func BadRequest(detail string) *Problem {
+	return New(http.StatusBadRequest, detail)
+}
+
+// NotFound ...
+func NotFound(detail string) *Problem {
+	return New(http.StatusNotFound, detail)
+}
+
+// Conflict ...
+func Conflict(detail string) *Problem {
+	return New(http.StatusConflict, detail)
+}
+
+// Internal ...
+// Creates a 500 problem caused by the given error. The error is logged but never written.
+func Internal(err error) *Problem {
+	return New(http.StatusInternalServerError, "").Wrap(err)
+}
+
+// With ...
+// Adds an extension member to the problem
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// Wrap ...
+// Records the underlying error which caused the problem
+func (p *Problem) Wrap(err error) *Problem {
+	p.cause = err
+	return p
+}
+
+func (p *Problem) Error() string {
+	msg := fmt.Sprintf("%d %s", p.Status, p.Title)
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	if p.cause != nil {
+		msg += ": " + p.cause.Error()
+	}
+	return msg
+}
+
+// Unwrap ...
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+// Problem ...
+// Implements Error
+func (p *Problem) Problem() *Problem {
+	return p
+}
+
+// MarshalJSON ...
+// Writes extension members alongside the standard members
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+// FromError ...
+// Maps the given error to a problem. Errors which implement Error map to their own
+// problem, oversized request bodies map to a 413 and deadlines map to a 504. All other
+// errors map to a 500 which does not reveal the error.
+func FromError(err error) *Problem {
+	var problemErr Error
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &problemErr):
+		return problemErr.Problem()
+	case errors.As(err, &maxBytesErr):
+		return Newf(http.StatusRequestEntityTooLarge, "Request body exceeds the maximum size of %d bytes", maxBytesErr.Limit).Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return New(http.StatusGatewayTimeout, "").Wrap(err)
+	default:
+		return Internal(err)
+	}
+}
+
+// Write ...
+// Writes the given problem as an application/problem+json response. The request ID
+// (if available) is included as the req_id member.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	resp := *p
+	if resp.Type == "" {
+		resp.Type = defaultType
+	}
+	if resp.Title == "" {
+		resp.Title = http.StatusText(resp.Status)
+	}
+	if resp.Instance == "" {
+		resp.Instance = r.URL.Path
+	}
+	if reqID, ok := hlog.IDFromRequest(r); ok {
+		resp.Extensions = make(map[string]interface{}, len(p.Extensions)+1)
+		for k, v := range p.Extensions {
+			resp.Extensions[k] = v
+		}
+		resp.Extensions["req_id"] = reqID.String()
+	}
+	body, _ := json.Marshal(&resp)
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(resp.Status)
+	w.Write(body) // nolint:errcheck
+}
+
+// WriteError ...
+// Maps the given error to a problem (see FromError) and writes it. Errors which map
+// to a server error are logged.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	p := FromError(err)
+	if p.Status >= http.StatusInternalServerError {
+		hlog.FromRequest(r).Error().Err(err).Int("status", p.Status).Msg("HTTP handler error")
+	} else {
+		hlog.FromRequest(r).Debug().Err(err).Int("status", p.Status).Msg("HTTP handler error")
+	}
+	Write(w, r, p)
+}
+
+// HandlerFunc ...
+// An HTTP handler which may return an error. Returned errors are written as problems
+// (see WriteError), so the handler must not have written a response if it returns an error.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f(w, r); err != nil {
+		WriteError(w, r, err)
+	}
+}
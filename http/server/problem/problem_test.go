@@ -0,0 +1,160 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"github.com/spals/starter-kit/http/server/problem"
+	"github.com/stretchr/testify/assert"
+)
+
+// A handler defined error type which maps to a problem
+type outOfStockError struct {
+	sku string
+}
+
+func (e *outOfStockError) Error() string {
+	return fmt.Sprintf("item %s is out of stock", e.sku)
+}
+
+func (e *outOfStockError) Problem() *problem.Problem {
+	p := problem.Conflict(e.Error()).With("sku", e.sku)
+	p.Type = "https://example.com/problems/out-of-stock"
+	p.Title = "Out of stock"
+	return p
+}
+
+func TestWrite(t *testing.T) {
+	assert := assert.New(t)
+	handler := withRequestID(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.BadRequest("Missing name").With("field", "name"))
+	})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/users?id=1", nil))
+	assert.Equal(http.StatusBadRequest, recorder.Code)
+	assert.Equal("application/problem+json", recorder.Header().Get("Content-Type"))
+
+	body := decodeProblem(t, recorder)
+	assert.Equal("about:blank", body["type"])
+	assert.Equal("Bad Request", body["title"])
+	assert.Equal(float64(400), body["status"])
+	assert.Equal("Missing name", body["detail"])
+	assert.Equal("/users", body["instance"])
+	assert.Equal("name", body["field"])
+	assert.Equal(recorder.Header().Get("Request-Id"), body["req_id"])
+	assert.NotEmpty(body["req_id"])
+}
+
+func TestHandlerFunc(t *testing.T) {
+	testCases := map[string]struct {
+		err            error
+		expectedStatus int
+		expectedTitle  string
+		expectedDetail interface{}
+	}{
+		"problem":        {problem.NotFound("No user 1"), http.StatusNotFound, "Not Found", "No user 1"},
+		"wrapped":        {fmt.Errorf("lookup: %w", problem.NotFound("No user 1")), http.StatusNotFound, "Not Found", "No user 1"},
+		"typed error":    {&outOfStockError{sku: "abc"}, http.StatusConflict, "Out of stock", "item abc is out of stock"},
+		"max bytes":      {&http.MaxBytesError{Limit: 16}, http.StatusRequestEntityTooLarge, "Request Entity Too Large", "Request body exceeds the maximum size of 16 bytes"},
+		"internal error": {errors.New("connection refused to db.internal"), http.StatusInternalServerError, "Internal Server Error", nil},
+		"internal cause": {problem.Internal(errors.New("connection refused to db.internal")), http.StatusInternalServerError, "Internal Server Error", nil},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			handler := withRequestID(problem.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return tc.err
+			}).ServeHTTP)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/items", nil))
+			assert.Equal(tc.expectedStatus, recorder.Code)
+			body := decodeProblem(t, recorder)
+			assert.Equal(tc.expectedTitle, body["title"])
+			assert.Equal(tc.expectedDetail, body["detail"])
+			// Internal errors are never revealed
+			assert.NotContains(recorder.Body.String(), "db.internal")
+		})
+	}
+}
+
+func TestHandlerFuncSuccess(t *testing.T) {
+	handler := problem.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/items", nil))
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Empty(t, recorder.Body.String())
+}
+
+func TestProblemError(t *testing.T) {
+	cause := errors.New("boom")
+	p := problem.Internal(cause)
+	assert.Equal(t, "500 Internal Server Error: boom", p.Error())
+	assert.True(t, errors.Is(p, cause))
+	assert.Equal(t, "404 Not Found: No user 1", problem.NotFound("No user 1").Error())
+}
+
+func TestRouterHandlers(t *testing.T) {
+	router := mux.NewRouter()
+	router.NotFoundHandler = problem.NotFoundHandler()
+	router.MethodNotAllowedHandler = problem.MethodNotAllowedHandler(router)
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/users/{id}", ok).Methods("GET", "PUT")
+	router.PathPrefix("/api").Subrouter().HandleFunc("/items", ok).Methods("POST")
+
+	testCases := map[string]struct {
+		method         string
+		path           string
+		expectedStatus int
+		expectedAllow  string
+	}{
+		"matched":                  {"GET", "/users/1", http.StatusOK, ""},
+		"not found":                {"GET", "/other", http.StatusNotFound, ""},
+		"method not allowed":       {"DELETE", "/users/1", http.StatusMethodNotAllowed, "GET, PUT"},
+		"subrouter not allowed":    {"GET", "/api/items", http.StatusMethodNotAllowed, "POST"},
+		"subrouter path not found": {"POST", "/api/other", http.StatusNotFound, ""},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(tc.expectedStatus, recorder.Code)
+			assert.Equal(tc.expectedAllow, recorder.Header().Get("Allow"))
+			if tc.expectedStatus != http.StatusOK {
+				body := decodeProblem(t, recorder)
+				assert.Equal(float64(tc.expectedStatus), body["status"])
+				assert.Equal(tc.path, body["instance"])
+			}
+		})
+	}
+}
+
+// ========== Private Helpers ==========
+
+// Wraps the given handler in a request logger and request ID handler
+func withRequestID(handler http.HandlerFunc) http.Handler {
+	return hlog.NewHandler(zerolog.New(io.Discard))(hlog.RequestIDHandler("req_id", "Request-Id")(handler))
+}
+
+func decodeProblem(t *testing.T, recorder *httptest.ResponseRecorder) map[string]interface{} {
+	assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	return body
+}
@@ -0,0 +1,49 @@
+package problem
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// The methods checked when computing the Allow header of a 405 response
+var allowMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// NotFoundHandler ...
+// Handler which writes a 404 problem, for use as a router's NotFoundHandler
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, Newf(http.StatusNotFound, "No route matches %s", r.URL.Path))
+	})
+}
+
+// MethodNotAllowedHandler ...
+// Handler which writes a 405 problem with an Allow header listing the methods which the
+// given router accepts for the request path, for use as the router's MethodNotAllowedHandler
+func MethodNotAllowedHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := allowedMethods(router, r)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		Write(w, r, Newf(http.StatusMethodNotAllowed, "Method %s is not allowed", r.Method).With("allowed_methods", allowed))
+	})
+}
+
+// ========== Private Helpers ==========
+
+// Returns the methods for which the given router has a route matching the request
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	allowed := make([]string, 0, len(allowMethods))
+	for _, method := range allowMethods {
+		methodReq := r.Clone(r.Context())
+		methodReq.Method = method
+		var match mux.RouteMatch
+		if router.Match(methodReq, &match) && match.MatchErr == nil && match.Route != nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/rs/zerolog/log"
+)
+
+// Supported values for HTTPServerConfig.Protocol
+const (
+	// HTTP/1.1 in plaintext (or HTTP/1.1 and HTTP/2 when TLS is enabled)
+	protocolHTTP1 = "http1"
+	// HTTP/2 in plaintext using prior knowledge (e.g. behind a service mesh sidecar)
+	protocolH2C = "h2c"
+	// HTTP/3 over QUIC (UDP) in addition to HTTP/1.1 and HTTP/2 over TLS (TCP)
+	protocolH3 = "h3"
+)
+
+// Configures the server delegates for the configured protocol mode. This must be
+// called after the TLS configuration is set on the server delegate.
+func (s *HTTPServer) configureProtocol() error {
+	switch s.config.Protocol {
+	case protocolHTTP1:
+		return nil
+	case protocolH2C:
+		if s.delegate.TLSConfig != nil {
+			return errors.New("the h2c protocol cannot be used with TLS. HTTP/2 is negotiated automatically when TLS is enabled")
+		}
+
+		s.delegate.Protocols = new(http.Protocols)
+		s.delegate.Protocols.SetHTTP1(true)
+		s.delegate.Protocols.SetUnencryptedHTTP2(true)
+		return nil
+	case protocolH3:
+		if s.delegate.TLSConfig == nil {
+			return errors.New("the h3 protocol requires TLS to be enabled")
+		}
+
+		s.h3Delegate = &http3.Server{
+			Handler:        s.delegate.Handler,
+			TLSConfig:      http3.ConfigureTLSConfig(s.delegate.TLSConfig),
+			IdleTimeout:    s.config.IdleTimeout,
+			MaxHeaderBytes: s.config.MaxHeaderBytes,
+		}
+		// Advertise HTTP/3 support to clients connecting over TCP
+		s.delegate.Handler = addAltSvcMiddleware(s.h3Delegate, s.delegate.Handler)
+		return nil
+	default:
+		return fmt.Errorf("unsupported protocol (%s). Available protocols are (http1|h2c|h3)", s.config.Protocol)
+	}
+}
+
+// Starts serving HTTP/3 on the UDP port which matches the active TCP port, if the h3
+// protocol is configured. Serve errors are sent to the given channel.
+func (s *HTTPServer) serveH3(serveErr chan<- error) error {
+	if s.h3Delegate == nil {
+		return nil
+	}
+
+	port := s.ActivePort()
+	if port == 0 {
+		return fmt.Errorf("the h3 protocol requires a TCP listener. Active address is %s:%s", s.ActiveAddr().Network(), s.ActiveAddr())
+	}
+	// A UDP socket inherited from a parent process during a binary upgrade takes precedence
+	h3Conn, err := inheritedPacketConn()
+	if err != nil {
+		return fmt.Errorf("HTTPServer HTTP/3 listen failure: %w", err)
+	}
+	if h3Conn == nil {
+		if h3Conn, err = net.ListenPacket("udp", fmt.Sprint(":", port)); err != nil {
+			return fmt.Errorf("HTTPServer HTTP/3 listen failure: %w", err)
+		}
+	}
+	s.h3Conn = h3Conn
+
+	go func() {
+		log.Info().Msgf("HTTPServer listening for HTTP/3 on UDP port :%d", port)
+		serveErr <- s.h3Delegate.Serve(h3Conn)
+	}()
+	return nil
+}
+
+// Gracefully shuts down the HTTP/3 server, if any. Note that the UDP connection
+// is not owned by the HTTP/3 server and so must be closed separately.
+func (s *HTTPServer) shutdownH3(ctx context.Context) error {
+	if s.h3Delegate == nil {
+		return nil
+	}
+
+	err := s.h3Delegate.Shutdown(ctx)
+	if s.h3Conn != nil {
+		s.h3Conn.Close() // nolint:errcheck
+	}
+	if err != nil && err != context.DeadlineExceeded {
+		return fmt.Errorf("HTTPServer HTTP/3 shutdown failure: %w", err)
+	}
+	return nil
+}
+
+func addAltSvcMiddleware(h3Delegate *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			h3Delegate.SetQUICHeaders(w.Header()) // nolint:errcheck
+		}
+		next.ServeHTTP(w, r)
+	})
+}
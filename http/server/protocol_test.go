@@ -0,0 +1,113 @@
+package server_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtocolHTTP1(t *testing.T) {
+	assert := assert.New(t)
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["PROTOCOL"] = "http1"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	client := &http.Client{}
+	urlBase := runTestServer(t, httpServer, client, "http")
+
+	resp, err := client.Get(fmt.Sprintf("%s/config", urlBase))
+	if assert.NoError(err) {
+		defer resp.Body.Close()
+		assert.Equal(200, resp.StatusCode)
+		assert.Equal("HTTP/1.1", resp.Proto)
+	}
+}
+
+func TestProtocolH2C(t *testing.T) {
+	assert := assert.New(t)
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["PROTOCOL"] = "h2c"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	// Use HTTP/2 with prior knowledge over a plaintext connection
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	urlBase := runTestServer(t, httpServer, client, "http")
+
+	resp, err := client.Get(fmt.Sprintf("%s/config", urlBase))
+	if assert.NoError(err) {
+		defer resp.Body.Close()
+		assert.Equal(200, resp.StatusCode)
+		assert.Equal("HTTP/2.0", resp.Proto)
+	}
+
+	// HTTP/1.1 clients are still supported
+	http1Resp, err := http.Get(fmt.Sprintf("%s/config", urlBase))
+	if assert.NoError(err) {
+		defer http1Resp.Body.Close()
+		assert.Equal("HTTP/1.1", http1Resp.Proto)
+	}
+}
+
+func TestProtocolH3(t *testing.T) {
+	assert := assert.New(t)
+	serverCert := newTestServerCert(t, "server", nil /*parent*/)
+	certFile, keyFile := writeTestCert(t, t.TempDir(), serverCert)
+
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["PROTOCOL"] = "h3"
+	configMap["TLS_CERT_FILE"] = certFile
+	configMap["TLS_KEY_FILE"] = keyFile
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverCert.cert)
+	tcpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}}}
+	urlBase := runTestServer(t, httpServer, tcpClient, "https")
+
+	// HTTP/3 is advertised to TCP clients
+	tcpResp, err := tcpClient.Get(fmt.Sprintf("%s/config", urlBase))
+	if assert.NoError(err) {
+		defer tcpResp.Body.Close()
+		assert.Equal(fmt.Sprintf(`h3=":%d"; ma=2592000`, httpServer.ActivePort()), tcpResp.Header.Get("Alt-Svc"))
+	}
+
+	h3Transport := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}}
+	defer h3Transport.Close()
+	h3Client := &http.Client{Transport: h3Transport}
+	resp, err := h3Client.Get(fmt.Sprintf("%s/config", urlBase))
+	if assert.NoError(err) {
+		defer resp.Body.Close()
+		assert.Equal(200, resp.StatusCode)
+		assert.Equal("HTTP/3.0", resp.Proto)
+	}
+}
+
+func TestProtocolInvalidConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir(), newTestServerCert(t, "server", nil /*parent*/))
+
+	for name, configMap := range map[string]map[string]string{
+		"unknown protocol": {"PROTOCOL": "spdy"},
+		"h2c with TLS":     {"PROTOCOL": "h2c", "TLS_CERT_FILE": certFile, "TLS_KEY_FILE": keyFile},
+		"h3 without TLS":   {"PROTOCOL": "h3"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			configMap["LOG_LEVEL"] = "trace"
+			httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+			assert.Error(t, httpServer.Run(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package route
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Route ...
+// A single HTTP endpoint to be registered in the HTTPServer router
+type Route struct {
+	// An optional unique name for the route (see mux.Route.Name)
+	Name string
+	// The path template of the route, relative to the group path prefix (e.g. /users/{id})
+	Path string
+	// The HTTP methods accepted by the route. If empty, then all methods are accepted.
+	Methods []string
+	Handler http.Handler
+	// Middleware applied to this route only. The first middleware is the outermost.
+	Middleware []mux.MiddlewareFunc
+	// The maximum request body size in bytes, overriding the server default (see HTTPServerConfig.MaxBodyBytes).
+	// A value of 0 uses the server default and a negative value removes the limit.
+	MaxBodyBytes int64
+	// The request content types accepted by the route (e.g. application/json). A type ending in /*
+	// matches all subtypes. If empty, then all content types are accepted.
+	ContentTypes []string
+	// The deadline applied to each request, overriding the server default (see HTTPServerConfig.RequestTimeout).
+	// A value of 0 uses the server default and a negative value removes the deadline.
+	Timeout time.Duration
+	// Require an authenticated caller (see auth.Authenticate)
+	Authenticated bool
+	// The scopes which an authenticated caller must hold. Requiring scopes implies Authenticated.
+	Scopes []string
+}
+
+// Group ...
+// A group of routes which share a path prefix and middleware
+type Group struct {
+	// An optional path prefix shared by all routes in the group (e.g. /api/v1)
+	PathPrefix string
+	// Middleware applied to all routes in the group. The first middleware is the outermost.
+	Middleware []mux.MiddlewareFunc
+	// Operational routes (e.g. health checks) are served by the admin server if it is enabled
+	Admin  bool
+	Routes []Route
+}
+
+// Provider ...
+// Provides a group of routes to be registered in the HTTPServer router.
+//
+// New route providers should be added to the handler.NewRouteProviders constructor.
+type Provider interface {
+	RouteGroup() Group
+}
+
+// Register ...
+// Registers the given route groups in the given router. Each group is registered
+// in its own subrouter.
+func Register(router *mux.Router, groups ...Group) {
+	for _, group := range groups {
+		// Skip groups without routes (e.g. a provider whose routes are disabled)
+		if len(group.Routes) == 0 {
+			continue
+		}
+		// Always use a subrouter so that group middleware does not apply to other groups
+		groupRouter := router.NewRoute().Subrouter()
+		if group.PathPrefix != "" {
+			groupRouter = router.PathPrefix(group.PathPrefix).Subrouter()
+		}
+		groupRouter.Use(group.Middleware...)
+
+		for _, route := range group.Routes {
+			methods := zerolog.Arr()
+			for _, method := range route.Methods {
+				methods.Str(method)
+			}
+			log.Debug().Str("path", group.PathPrefix+route.Path).Array("methods", methods).Msg("Adding HTTP handler")
+
+			handler := &registeredHandler{Handler: chain(route.Handler, route.Middleware...), route: route}
+			muxRoute := groupRouter.Path(route.Path).Handler(handler)
+			if len(route.Methods) > 0 {
+				muxRoute.Methods(route.Methods...)
+			}
+			if route.Name != "" {
+				muxRoute.Name(route.Name)
+			}
+		}
+	}
+}
+
+// Current ...
+// Returns the declaration of the registered route matched by the given request, if any.
+// This allows router middleware to apply route specific behavior.
+//
+// Note that the matched route is only known within the router, so middleware which
+// depends on it must be registered as mux middleware (i.e. with mux.Router.Use).
+func Current(r *http.Request) (Route, bool) {
+	muxRoute := mux.CurrentRoute(r)
+	if muxRoute == nil {
+		return Route{}, false
+	}
+	handler, ok := muxRoute.GetHandler().(*registeredHandler)
+	if !ok {
+		return Route{}, false
+	}
+	return handler.route, true
+}
+
+// ========== Private Helpers ==========
+
+// A route handler which holds the declaration of its route
+type registeredHandler struct {
+	http.Handler
+	route Route
+}
+
+func chain(h http.Handler, m ...mux.MiddlewareFunc) http.Handler {
+	for i := len(m) - 1; i >= 0; i-- {
+		h = m[i](h)
+	}
+	return h
+}
@@ -0,0 +1,101 @@
+package route_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/spals/starter-kit/http/server/route"
+	"github.com/stretchr/testify/assert"
+)
+
+// Middleware which appends the given value to the X-Middleware response header
+func headerMiddleware(value string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestRegister(t *testing.T) {
+	assert := assert.New(t)
+	router := mux.NewRouter()
+	route.Register(router,
+		route.Group{
+			Routes: []route.Route{
+				{Name: "root", Path: "/root", Methods: []string{"GET"}, Handler: http.HandlerFunc(okHandler)},
+			},
+		},
+		route.Group{
+			PathPrefix: "/api/v1",
+			Middleware: []mux.MiddlewareFunc{headerMiddleware("group")},
+			Routes: []route.Route{
+				{Path: "/users/{id}", Methods: []string{"GET", "PUT"}, Handler: http.HandlerFunc(okHandler),
+					Middleware: []mux.MiddlewareFunc{headerMiddleware("route-1"), headerMiddleware("route-2")}},
+				{Path: "/any", Handler: http.HandlerFunc(okHandler)},
+			},
+		},
+	)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		return recorder
+	}
+
+	// Group middleware does not apply to other groups
+	resp := serve("GET", "/root")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Empty(resp.Header().Values("X-Middleware"))
+
+	// Group middleware applies before route middleware, in order
+	resp = serve("PUT", "/api/v1/users/1")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal([]string{"group", "route-1", "route-2"}, resp.Header().Values("X-Middleware"))
+
+	// Routes without methods accept all methods
+	assert.Equal(http.StatusOK, serve("DELETE", "/api/v1/any").Code)
+
+	assert.Equal(http.StatusMethodNotAllowed, serve("POST", "/root").Code)
+	assert.Equal(http.StatusMethodNotAllowed, serve("DELETE", "/api/v1/users/1").Code)
+	assert.Equal(http.StatusNotFound, serve("GET", "/users/1").Code)
+	assert.NotNil(router.Get("root"))
+}
+
+func TestCurrent(t *testing.T) {
+	assert := assert.New(t)
+	router := mux.NewRouter()
+	route.Register(router, route.Group{
+		PathPrefix: "/api/v1",
+		Routes: []route.Route{
+			{Name: "create-user", Path: "/users", Methods: []string{"POST"}, Handler: http.HandlerFunc(okHandler),
+				MaxBodyBytes: 1024, ContentTypes: []string{"application/json"}},
+		},
+	})
+
+	var current route.Route
+	var found bool
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current, found = route.Current(r)
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/v1/users", nil))
+	if assert.True(found) {
+		assert.Equal("create-user", current.Name)
+		assert.Equal(int64(1024), current.MaxBodyBytes)
+		assert.Equal([]string{"application/json"}, current.ContentTypes)
+	}
+
+	_, found = route.Current(httptest.NewRequest("GET", "/", nil))
+	assert.False(found)
+}
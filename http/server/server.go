@@ -2,179 +2,390 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/heptiolabs/healthcheck"
-	"github.com/rs/zerolog"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/rs/zerolog/hlog"
 	"github.com/rs/zerolog/log"
+	"github.com/spals/starter-kit/http/server/auth"
 	"github.com/spals/starter-kit/http/server/config"
-	"github.com/spals/starter-kit/http/server/handler"
+	"github.com/spals/starter-kit/http/server/middleware"
+	"github.com/spals/starter-kit/http/server/problem"
+	"github.com/spals/starter-kit/http/server/route"
 )
 
+// The time allowed to flush spans on shutdown. This is separate from the ShutdownTimeout,
+// which draining connections may use up entirely.
+const tracingShutdownTimeout = 5 * time.Second
+
 // HTTPServer ...
 type HTTPServer struct {
 	config   *config.HTTPServerConfig
 	delegate *http.Server
-}
+	// The server for operational endpoints, if the admin server is enabled
+	adminDelegate *http.Server
+	// The HTTP/3 server and its UDP connection, if the h3 protocol is configured
+	h3Delegate *http3.Server
+	h3Conn     net.PacketConn
+	// Request tracing, which must be flushed on shutdown
+	tracing *middleware.Tracing
+	// Set to 1 once the server has begun draining in preparation for shutdown
+	draining int32
 
-// Function callback definition used to register routes in HTTPServer router
-type routerRegistration func(router *mux.Router)
+	// Set to 1 while a binary upgrade is in progress
+	upgrading int32
+
+	listenerMu      sync.RWMutex
+	listener        net.Listener
+	adminListenerMu sync.RWMutex
+	adminListener   net.Listener
+}
 
 // NewHTTPServer ...
-// Create a new HTTPServer with the given configuration and request handlers.
+// Create a new HTTPServer with the given configuration and route providers.
 //
-// New request handlers should be added here and in wire.go and registered in
-// the router below.
+// New request handlers should implement route.Provider and be added to
+// handler.NewRouteProviders. There is no need to modify this constructor.
 func NewHTTPServer(
 	config *config.HTTPServerConfig,
 	healthCheckHandler *healthcheck.Handler,
-	httpServerConfigHandler *handler.HTTPServerConfigHandler,
+	panicCounter *middleware.PanicCounter,
+	metrics *middleware.Metrics,
+	tracing *middleware.Tracing,
+	jwtVerifier *auth.JWTVerifier,
+	apiKeyVerifier *auth.APIKeyVerifier,
+	routeProviders []route.Provider,
 ) *HTTPServer {
-	router := makeRouter(
-		config,
-		func(router *mux.Router) {
-			log.Debug().Str("path", "/config").Array("methods", zerolog.Arr().Str("GET")).Msg("Adding HTTP handler")
-			router.Path("/config").Methods("GET").Handler(httpServerConfigHandler)
-
-			log.Debug().Str("path", "/live").Array("methods", zerolog.Arr().Str("GET")).Msg("Adding HTTP handler")
-			router.Path("/live").Methods("GET").HandlerFunc((*healthCheckHandler).LiveEndpoint)
-
-			log.Debug().Str("path", "/ready").Array("methods", zerolog.Arr().Str("GET")).Msg("Adding HTTP handler")
-			router.Path("/ready").Methods("GET").HandlerFunc((*healthCheckHandler).ReadyEndpoint)
-		},
-	)
-	delegate := &http.Server{Handler: router}
+	// Operational route groups are registered in the admin router if the admin server is enabled.
+	// Otherwise, all route groups are registered in the main router.
+	var appGroups, adminGroups []route.Group
+	for _, routeProvider := range routeProviders {
+		group := routeProvider.RouteGroup()
+		if group.Admin && config.AdminEnabled() {
+			adminGroups = append(adminGroups, group)
+		} else {
+			appGroups = append(appGroups, group)
+		}
+	}
 
-	httpServer := &HTTPServer{config, delegate}
+	delegate := makeDelegate(config, makeRouter(config, appGroups, panicCounter, metrics, tracing, jwtVerifier, apiKeyVerifier))
+	var adminDelegate *http.Server
+	if config.AdminEnabled() {
+		// Admin requests (e.g. probes and metrics scrapes) are not recorded so that they
+		// are not mixed into the application request metrics
+		adminDelegate = makeDelegate(config, makeRouter(config, adminGroups, panicCounter, nil /*metrics*/, tracing, jwtVerifier, apiKeyVerifier))
+	}
+
+	httpServer := &HTTPServer{config: config, delegate: delegate, adminDelegate: adminDelegate, tracing: tracing}
+	// Fail readiness as soon as the server begins draining so that load balancers stop routing traffic to it
+	log.Debug().Str("name", "draining").Msg("Adding readiness check")
+	(*healthCheckHandler).AddReadinessCheck("draining", httpServer.drainingCheck)
 	return httpServer
 }
 
+// ActiveAddr ...
+// Returns the address on which the server is actively listening, or nil if the
+// server is not yet listening. This is useful as the server is capable of using a
+// randomly assigned port, a Unix domain socket or a socket passed by systemd.
+func (s *HTTPServer) ActiveAddr() net.Addr {
+	listener := s.activeListener()
+	if listener == nil {
+		return nil
+	}
+	return listener.Addr()
+}
+
 // ActivePort ...
-// Returns the port on which the server is actively listening.
-// This is useful as the server is capable or using a randomly assigned port.
+// Returns the port on which the server is actively listening, or 0 if the server
+// is not yet listening or is not listening on a TCP address.
 func (s *HTTPServer) ActivePort() int {
-	// Note that the port will be re-written in the configuration if a random one is used.
-	return s.config.Port
+	if tcpAddr, ok := s.ActiveAddr().(*net.TCPAddr); ok {
+		return tcpAddr.Port
+	}
+	return 0
 }
 
 // Start ...
+// Runs the server until it is shut down. This is a thin wrapper around Run
+// for callers which manage the server lifecycle via Shutdown.
 func (s *HTTPServer) Start() {
-	// Include a graceful server shutdown sequence
-	// See https://medium.com/honestbee-tw-engineer/gracefully-shutdown-in-go-http-server-5f5e6b83da5a#16fd
-	httpServerStopped := make(chan os.Signal, 1)
-	signal.Notify(httpServerStopped, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	if err := s.Run(context.Background()); err != nil {
+		log.Error().Err(err).Msg("HTTPServer run failure")
+	}
+}
+
+// Run ...
+// Serves requests until the given context is cancelled, at which point the
+// server is gracefully shut down. Any error encountered while binding,
+// serving or shutting down is returned to the caller.
+func (s *HTTPServer) Run(ctx context.Context) error {
+	tlsConfig, err := makeTLSConfig(s.config.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("HTTPServer TLS configuration failure: %w", err)
+	}
+	s.delegate.TLSConfig = tlsConfig
+	if err := s.configureProtocol(); err != nil {
+		return fmt.Errorf("HTTPServer protocol configuration failure: %w", err)
+	}
 
-	customListener := s.makeCustomListener()
+	listener, err := s.makeListener()
+	if err != nil {
+		return err
+	}
+	s.setActiveListener(listener)
+	// Resolve the admin port before any server starts serving, as handlers (e.g. /config) read the configuration
+	adminListener, err := s.makeAdminListener()
+	if err != nil {
+		listener.Close() // nolint:errcheck
+		return err
+	}
 
+	// Buffer serve errors from the primary, the (optional) HTTP/3 and the (optional) admin servers
+	serveErr := make(chan error, 3)
+	running := 1
 	go func() {
-		// If we do not have a custom listener, then use the default listener
-		if customListener == nil {
-			s.delegate.Addr = fmt.Sprint(":", s.config.Port)
-			log.Info().Msgf("HTTPServer listening on port %s", s.delegate.Addr)
-			if err := s.delegate.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Fatal().Err(err).Msg("HTTPServer start failure with default listener")
-			}
+		log.Info().Bool("tls", tlsConfig != nil).Str("protocol", s.config.Protocol).Msgf("HTTPServer listening on %s:%s", listener.Addr().Network(), listener.Addr())
+		if tlsConfig != nil {
+			// Certificates are served from the TLS configuration
+			serveErr <- s.delegate.ServeTLS(listener, "" /*certFile*/, "" /*keyFile*/)
 		} else {
-			log.Info().Msgf("HTTPServer listening on port :%d", customListener.Addr().(*net.TCPAddr).Port)
-			if err := s.delegate.Serve(customListener); err != nil && err != http.ErrServerClosed {
-				log.Fatal().Err(err).Msg("HTTPServer start failure with custom listener")
-			}
+			serveErr <- s.delegate.Serve(listener)
 		}
 	}()
+	if err := s.serveH3(serveErr); err != nil {
+		if adminListener != nil {
+			adminListener.Close() // nolint:errcheck
+		}
+		s.stopAfterFailure(serveErr, running)
+		return err
+	} else if s.h3Delegate != nil {
+		running++
+	}
+	s.serveAdmin(adminListener, serveErr)
+	if adminListener != nil {
+		running++
+	}
 	log.Info().Msg("HTTPServer started")
+	// If this process was started by a binary upgrade, then let the parent process know that it can exit
+	s.notifyUpgradeReady()
 
-	<-httpServerStopped
-	log.Info().Msg("HTTPServer stopped")
-	s.Shutdown()
+	select {
+	case err := <-serveErr:
+		if err != nil && err != http.ErrServerClosed {
+			s.stopAfterFailure(serveErr, running-1)
+			return fmt.Errorf("HTTPServer serve failure: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		log.Info().Msg("HTTPServer stopped")
+		if err := s.Shutdown(); err != nil {
+			// Ensure that every server is stopped even if an earlier one failed to shut down
+			s.stopAfterFailure(serveErr, running)
+			return err
+		}
+		// Wait for the serve goroutines so that none outlive this call
+		for ; running > 0; running-- {
+			<-serveErr
+		}
+		return nil
+	}
 }
 
 // Shutdown ...
-func (s *HTTPServer) Shutdown() {
+// Drains the server for the configured delay and then gracefully shuts it down.
+// If in-flight requests do not complete within the configured shutdown timeout,
+// then all remaining connections are forcibly closed.
+func (s *HTTPServer) Shutdown() error {
+	s.drain()
+
 	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
 	defer func() {
 		cancel()
 	}()
 
 	log.Info().Msg("Shutting down HTTPServer")
-	if err := s.delegate.Shutdown(ctx); err != nil {
-		log.Fatal().Err(err).Msg("HTTPServer shutdown failed")
+	if err := s.shutdownH3(ctx); err != nil {
+		return err
+	}
+	if err := s.shutdownDelegate(ctx, s.delegate); err != nil {
+		return err
+	}
+	// Shutdown the admin server last so that operational endpoints are available for as long as possible
+	if err := s.shutdownAdmin(ctx); err != nil {
+		return err
+	}
+	// Flush any spans from the final requests, even if connections were forced closed
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer tracingCancel()
+	if err := s.tracing.Shutdown(tracingCtx); err != nil {
+		return fmt.Errorf("HTTPServer tracing shutdown failure: %w", err)
 	}
 	log.Info().Msg("HTTPServer shutdown")
+	return nil
 }
 
-// If a random port is requested, then make a custom listener on an open port
-// Otherwise, return nil
-// See https://stackoverflow.com/questions/43424787/how-to-use-next-available-port-in-http-listenandserve
-func (s *HTTPServer) makeCustomListener() net.Listener {
-	if s.config.Port == 0 {
-		log.Debug().Msg("Finding available random port")
-		listener, err := net.Listen("tcp", ":0")
-		if err != nil {
-			log.Fatal().Err(err).Msg("Error while finding random port")
+// Gracefully shuts down the given server delegate, forcibly closing any remaining
+// connections if the shutdown context expires
+func (s *HTTPServer) shutdownDelegate(ctx context.Context, delegate *http.Server) error {
+	if err := delegate.Shutdown(ctx); err != nil {
+		if err != context.DeadlineExceeded {
+			return fmt.Errorf("HTTPServer shutdown failure: %w", err)
+		}
+
+		log.Warn().Dur("timeout", s.config.ShutdownTimeout).Msg("HTTPServer shutdown timed out. Forcing connections closed")
+		if err := delegate.Close(); err != nil {
+			return fmt.Errorf("HTTPServer close failure: %w", err)
 		}
+	}
+	return nil
+}
+
+// Stops every server after one of them fails to start or serve, and then waits for the
+// given number of serve goroutines which are still running to return
+func (s *HTTPServer) stopAfterFailure(serveErr <-chan error, running int) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+
+	s.shutdownH3(ctx)                   // nolint:errcheck
+	s.shutdownDelegate(ctx, s.delegate) // nolint:errcheck
+	s.shutdownAdmin(ctx)                // nolint:errcheck
+	for ; running > 0; running-- {
+		<-serveErr
+	}
+}
+
+func (s *HTTPServer) activeListener() net.Listener {
+	s.listenerMu.RLock()
+	defer s.listenerMu.RUnlock()
+	return s.listener
+}
 
-		newPort := listener.Addr().(*net.TCPAddr).Port
-		log.Info().Msgf("Overwriting configured port (%d) with random port (%d)", s.config.Port, newPort)
-		s.config.Port = newPort
-		return listener
+func (s *HTTPServer) setActiveListener(listener net.Listener) {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	s.listener = listener
+}
+
+// Marks the server as draining (which fails readiness checks), disables
+// keep-alives and waits for the configured drain delay
+func (s *HTTPServer) drain() {
+	if !atomic.CompareAndSwapInt32(&s.draining, 0, 1) {
+		return
+	}
+
+	s.delegate.SetKeepAlivesEnabled(false)
+	if s.adminDelegate != nil {
+		s.adminDelegate.SetKeepAlivesEnabled(false)
 	}
+	if s.config.DrainDelay > 0 {
+		log.Info().Dur("delay", s.config.DrainDelay).Msg("Draining HTTPServer")
+		time.Sleep(s.config.DrainDelay)
+	}
+}
 
+func (s *HTTPServer) drainingCheck() error {
+	if atomic.LoadInt32(&s.draining) == 1 {
+		return errors.New("HTTPServer is draining")
+	}
 	return nil
 }
 
 // ========== Private Helpers ==========
 
-// See https://gist.github.com/husobee/fd23681261a39699ee37
-type middleware func(http.Handler) http.Handler
-
 func addLoggingMiddleware(
 	config *config.HTTPServerConfig,
-	rootHandler http.Handler,
+	router *mux.Router,
+	panicCounter *middleware.PanicCounter,
+	metrics *middleware.Metrics,
+	tracing *middleware.Tracing,
 ) http.Handler {
-	return buildChain(
-		rootHandler,
+	chain := []mux.MiddlewareFunc{
 		hlog.NewHandler(config.ReqLogger),
 		hlog.AccessHandler(func(r *http.Request, status, size int, duration time.Duration) {
 			hlog.FromRequest(r).Info().
 				Str("method", r.Method).
+				Str("proto", r.Proto).
 				Stringer("url", r.URL).
 				Int("status", status).
 				Int("size", size).
 				Dur("duration", duration).
 				Msg("Finished HTTP request")
 		}),
+		// Recover panics from all other middleware and the router (e.g. its not found handler), which
+		// are logged without a route. Panics from routes are recovered by the router middleware.
+		middleware.Recovery(panicCounter),
+		// Compression is registered immediately after the access log so that its size is the compressed size
+		middleware.Compress(config.CompressionConfig),
+		tracing.Middleware(router),
+	}
+	// Metrics are omitted (i.e. nil) for routers whose requests should not be recorded
+	if metrics != nil {
+		chain = append(chain, metrics.Middleware(router))
+	}
+	chain = append(chain,
 		hlog.RemoteAddrHandler("ip"),
 		hlog.UserAgentHandler("user_agent"),
 		hlog.RefererHandler("referer"),
 		hlog.RequestIDHandler("req_id", "Request-Id"),
+		auth.PeerIdentityHandler(),
+		middleware.CORS(config.CORSConfig, router),
 	)
+	return buildChain(router, chain...)
 }
 
-func buildChain(f http.Handler, m ...middleware) http.Handler {
+// See https://gist.github.com/husobee/fd23681261a39699ee37
+func buildChain(f http.Handler, m ...mux.MiddlewareFunc) http.Handler {
 	// If our chain is done, use the original handler
 	if len(m) == 0 {
 		return f
 	}
 	// Otherwise nest the handlers
-	return m[0](buildChain(f, m[1:cap(m)]...))
+	return m[0](buildChain(f, m[1:]...))
+}
+
+// Create a server delegate with the configured timeouts and limits
+func makeDelegate(config *config.HTTPServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
 }
 
 func makeRouter(
 	config *config.HTTPServerConfig,
-	routerRegistration routerRegistration,
+	groups []route.Group,
+	panicCounter *middleware.PanicCounter,
+	metrics *middleware.Metrics,
+	tracing *middleware.Tracing,
+	jwtVerifier *auth.JWTVerifier,
+	apiKeyVerifier *auth.APIKeyVerifier,
 ) http.Handler {
 	router := mux.NewRouter()
-	routerRegistration(router)
+	router.NotFoundHandler = problem.NotFoundHandler()
+	router.MethodNotAllowedHandler = problem.MethodNotAllowedHandler(router)
+	route.Register(router, groups...)
+	// Recovery is the outermost router middleware so that it recovers panics from all
+	// other middleware while still knowing the matched route
+	router.Use(middleware.Recovery(panicCounter))
+	// Rate limits are applied after authentication so that clients may be identified by their verified credentials
+	router.Use(auth.Authenticate(jwtVerifier, apiKeyVerifier))
+	router.Use(middleware.RateLimit(config.RateLimitConfig))
+	router.Use(middleware.RequestBody(config.MaxBodyBytes))
+	router.Use(middleware.Timeout(config.RequestTimeout, panicCounter))
 
-	// Wrap router in a logging handler in order to create access logs
-	routerWithLogging := addLoggingMiddleware(config, router)
+	// Wrap router in a logging handler in order to create access logs, metrics and traces
+	routerWithLogging := addLoggingMiddleware(config, router, panicCounter, metrics, tracing)
 	return routerWithLogging
 }
@@ -1,8 +1,11 @@
 package server_test
 
 import (
+	"context"
 	"fmt"
+	"io"
 	nativelog "log"
+	"net"
 	"net/http"
 	"testing"
 	"time"
@@ -84,3 +87,148 @@ func (s *HTTPServerTestSuite) TestGetConfig() {
 		assert.Equal("application/json; charset=utf-8", resp.Header.Get("Content-Type"))
 	}
 }
+
+func (s *HTTPServerTestSuite) TestGetMetrics() {
+	assert := assert.New(s.T())
+	configResp, err := http.Get(fmt.Sprintf("%s/config", s.httpURLBase))
+	if assert.NoError(err) {
+		configResp.Body.Close()
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/metrics", s.httpURLBase))
+	if assert.NoError(err) {
+		defer resp.Body.Close()
+		assert.Equal(200, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(string(body), `http_server_requests_total{method="GET",route="/config",status="2xx"}`)
+		assert.Contains(string(body), "go_goroutines")
+		assert.Contains(string(body), "process_open_fds")
+	}
+}
+
+func (s *HTTPServerTestSuite) TestUnmatchedRoutes() {
+	assert := assert.New(s.T())
+	resp, err := http.Get(fmt.Sprintf("%s/missing", s.httpURLBase))
+	if assert.NoError(err) {
+		resp.Body.Close()
+		assert.Equal(404, resp.StatusCode)
+		assert.Equal("application/problem+json", resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Post(fmt.Sprintf("%s/config", s.httpURLBase), "application/json", nil)
+	if assert.NoError(err) {
+		resp.Body.Close()
+		assert.Equal(405, resp.StatusCode)
+		assert.Equal("application/problem+json", resp.Header.Get("Content-Type"))
+		assert.Equal("GET", resp.Header.Get("Allow"))
+	}
+}
+
+// ========== Lifecycle Tests ==========
+
+func TestRunBindFailure(t *testing.T) {
+	assert := assert.New(t)
+	// Occupy a port so that the HTTPServer cannot bind to it
+	listener, err := net.Listen("tcp", ":0")
+	if !assert.NoError(err) {
+		return
+	}
+	defer listener.Close()
+
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["PORT"] = fmt.Sprint(listener.Addr().(*net.TCPAddr).Port)
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	assert.Error(httpServer.Run(context.Background()))
+}
+
+func TestRunContextCancel(t *testing.T) {
+	assert := assert.New(t)
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+
+	assert.Eventually(func() bool {
+		if httpServer.ActivePort() == 0 {
+			return false
+		}
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/ready", httpServer.ActivePort()))
+		return err == nil && resp.StatusCode == 200
+	}, serverStartTimeoutMs*time.Millisecond /*waitFor*/, serverStartTickMs*time.Millisecond /*tick*/)
+
+	cancel()
+	select {
+	case err := <-runErr:
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		assert.Fail("HTTPServer did not stop after context cancellation")
+	}
+}
+
+func TestShutdownDrain(t *testing.T) {
+	assert := assert.New(t)
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["DRAIN_DELAY"] = "500ms"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+
+	readyStatus := func() int {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/ready", httpServer.ActivePort()))
+		if err != nil {
+			return 0
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Eventually(func() bool {
+		return httpServer.ActivePort() != 0 && readyStatus() == 200
+	}, serverStartTimeoutMs*time.Millisecond /*waitFor*/, serverStartTickMs*time.Millisecond /*tick*/)
+
+	// Readiness should fail while the server is still accepting requests during the drain delay
+	cancel()
+	assert.Eventually(func() bool {
+		return readyStatus() == 503
+	}, serverStartTimeoutMs*time.Millisecond /*waitFor*/, serverStartTickMs*time.Millisecond /*tick*/)
+	assert.NoError(<-runErr)
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	assert := assert.New(t)
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["READ_HEADER_TIMEOUT"] = "50ms"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+	runTestServer(t, httpServer, &http.Client{}, "http")
+
+	// A client which never finishes sending its headers is disconnected
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", httpServer.ActivePort()))
+	if !assert.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /ready HTTP/1.1\r\nHost: localhost\r\n"))
+	assert.NoError(err)
+
+	assert.NoError(conn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, 1024)
+	_, err = conn.Read(buf)
+	// The server either closes the connection or responds with an error before closing it
+	for err == nil {
+		_, err = conn.Read(buf)
+	}
+	netErr, isNetErr := err.(net.Error)
+	assert.False(isNetErr && netErr.Timeout(), "Connection was not closed by the server")
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spals/starter-kit/http/server/config"
+)
+
+// Supported values for TLSConfig.MinVersion
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Supported values for TLSConfig.ClientAuth
+var tlsClientAuthTypes = map[string]tls.ClientAuthType{
+	"none":    tls.NoClientCert,
+	"request": tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAnyClientCert,
+	"verify":  tls.RequireAndVerifyClientCert,
+}
+
+// certReloader ...
+// Serves a TLS certificate loaded from disk, reloading it whenever the
+// certificate or key files change (e.g. when a Kubernetes secret is rotated).
+//
+// Files are checked lazily during TLS handshakes, at most once per reload interval.
+// Reloads happen in the background so that handshakes are never blocked on file I/O.
+type certReloader struct {
+	certFile       string
+	keyFile        string
+	reloadInterval time.Duration
+
+	mu          sync.RWMutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastChecked time.Time
+	// Set to 1 while a background reload is in progress
+	reloading int32
+}
+
+func newCertReloader(tlsConfig *config.TLSConfig) *certReloader {
+	return &certReloader{
+		certFile:       tlsConfig.CertFile,
+		keyFile:        tlsConfig.KeyFile,
+		reloadInterval: tlsConfig.ReloadInterval,
+	}
+}
+
+// GetCertificate ...
+// Implements the tls.Config GetCertificate callback
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Load ...
+// Forces an initial load of the certificate. An error is returned if the
+// certificate cannot be loaded.
+func (r *certReloader) Load() error {
+	return r.load()
+}
+
+// Reloads the certificate in the background if the reload interval has elapsed
+func (r *certReloader) maybeReload() {
+	r.mu.RLock()
+	stale := time.Since(r.lastChecked) >= r.reloadInterval
+	r.mu.RUnlock()
+	if !stale || !atomic.CompareAndSwapInt32(&r.reloading, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&r.reloading, 0)
+		if err := r.load(); err != nil {
+			// Continue serving the previous certificate if the new one is not (yet) valid
+			log.Error().Err(err).Str("cert_file", r.certFile).Msg("TLS certificate reload failure")
+		}
+	}()
+}
+
+// Loads the certificate if either the certificate or the key file has changed. Files are
+// read without holding the lock, which is only held to swap in the new certificate.
+func (r *certReloader) load() error {
+	r.mu.Lock()
+	r.lastChecked = time.Now()
+	loaded, certModTime, keyModTime := r.cert != nil, r.certModTime, r.keyModTime
+	r.mu.Unlock()
+
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("unable to read TLS certificate file: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to read TLS key file: %w", err)
+	}
+	if loaded && certInfo.ModTime().Equal(certModTime) && keyInfo.ModTime().Equal(keyModTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load TLS key pair: %w", err)
+	}
+
+	log.Info().Str("cert_file", r.certFile).Str("key_file", r.keyFile).Msg("TLS certificate loaded")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return nil
+}
+
+// Builds the TLS configuration used by the server. Returns nil if TLS is not enabled.
+func makeTLSConfig(tlsConfig *config.TLSConfig) (*tls.Config, error) {
+	if !tlsConfig.Enabled() {
+		return nil, nil
+	}
+
+	minVersion, ok := tlsVersions[tlsConfig.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS minimum version (%s). Available versions are (1.0|1.1|1.2|1.3)", tlsConfig.MinVersion)
+	}
+
+	cipherSuites, err := parseCipherSuites(tlsConfig.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	clientAuth, ok := tlsClientAuthTypes[tlsConfig.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS client auth mode (%s). Available modes are (none|request|require|verify)", tlsConfig.ClientAuth)
+	}
+	clientCAs, err := loadClientCAs(tlsConfig, clientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader := newCertReloader(tlsConfig)
+	if err := reloader.Load(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: reloader.GetCertificate,
+		ClientAuth:     clientAuth,
+		ClientCAs:      clientCAs,
+	}, nil
+}
+
+// Loads the CA bundle used to verify client certificates. A bundle is required
+// for any client auth mode which verifies certificates.
+func loadClientCAs(tlsConfig *config.TLSConfig, clientAuth tls.ClientAuthType) (*x509.CertPool, error) {
+	if tlsConfig.ClientCAFile == "" {
+		if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+			return nil, fmt.Errorf("a TLS client CA file is required for client auth mode (%s)", tlsConfig.ClientAuth)
+		}
+		return nil, nil
+	}
+
+	clientCAPEM, err := ioutil.ReadFile(tlsConfig.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read TLS client CA file: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(clientCAPEM) {
+		return nil, fmt.Errorf("no certificates found in TLS client CA file (%s)", tlsConfig.ClientCAFile)
+	}
+	return clientCAs, nil
+}
+
+// Converts cipher suite names to their IDs. Only secure cipher suites are allowed.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	available := make(map[string]uint16)
+	for _, cipherSuite := range tls.CipherSuites() {
+		available[cipherSuite.Name] = cipherSuite.ID
+	}
+
+	cipherSuites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS cipher suite (%s)", name)
+		}
+		cipherSuites = append(cipherSuites, id)
+	}
+	return cipherSuites, nil
+}
@@ -0,0 +1,292 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Test Helpers ==========
+
+// A certificate and its private key, along with the parsed certificate
+type testCert struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+	keyPEM  []byte
+}
+
+// Creates a certificate with the given common name. If parent is nil, then the
+// certificate is a self-signed CA. Otherwise, it is signed by the parent.
+func newTestCert(t *testing.T, commonName string, parent *testCert, template *x509.Certificate) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	if template == nil {
+		template = &x509.Certificate{}
+	}
+	template.SerialNumber = serial
+	template.Subject = pkix.Name{CommonName: commonName}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign
+
+	parentCert, parentKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:     key,
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// Creates a localhost server certificate
+func newTestServerCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	return newTestCert(t, commonName, parent, &x509.Certificate{
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+// Writes the certificate and key to the given directory and returns their file paths
+func writeTestCert(t *testing.T, dir string, c *testCert) (string, string) {
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	require.NoError(t, ioutil.WriteFile(certFile, c.certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, c.keyPEM, 0600))
+	return certFile, keyFile
+}
+
+// Runs the HTTPServer until the test completes and waits for it to be ready
+func runTestServer(t *testing.T, httpServer *server.HTTPServer, client *http.Client, scheme string) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-runErr)
+	})
+
+	var urlBase string
+	assert.Eventually(t, func() bool {
+		if httpServer.ActivePort() == 0 {
+			return false
+		}
+		urlBase = fmt.Sprintf("%s://localhost:%d", scheme, httpServer.ActivePort())
+		resp, err := client.Get(fmt.Sprintf("%s/ready", urlBase))
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == 200
+	}, serverStartTimeoutMs*time.Millisecond /*waitFor*/, serverStartTickMs*time.Millisecond /*tick*/)
+	return urlBase
+}
+
+// Returns an unused TCP port
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// ========== Tests ==========
+
+func TestTLS(t *testing.T) {
+	for name, port := range map[string]int{"random port": 0, "fixed port": freePort(t)} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			serverCert := newTestServerCert(t, "server", nil /*parent*/)
+			certFile, keyFile := writeTestCert(t, t.TempDir(), serverCert)
+
+			configMap := make(map[string]string)
+			configMap["LOG_LEVEL"] = "trace"
+			configMap["PORT"] = fmt.Sprint(port)
+			configMap["TLS_CERT_FILE"] = certFile
+			configMap["TLS_KEY_FILE"] = keyFile
+			httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+			rootCAs := x509.NewCertPool()
+			rootCAs.AddCert(serverCert.cert)
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}}}
+			urlBase := runTestServer(t, httpServer, client, "https")
+
+			resp, err := client.Get(fmt.Sprintf("%s/config", urlBase))
+			if assert.NoError(err) {
+				defer resp.Body.Close()
+				assert.Equal(200, resp.StatusCode)
+				assert.Equal("server", resp.TLS.PeerCertificates[0].Subject.CommonName)
+			}
+		})
+	}
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	assert := assert.New(t)
+	serverCert := newTestServerCert(t, "server", nil /*parent*/)
+	certFile, keyFile := writeTestCert(t, t.TempDir(), serverCert)
+
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["TLS_CERT_FILE"] = certFile
+	configMap["TLS_KEY_FILE"] = keyFile
+	configMap["TLS_MIN_VERSION"] = "1.3"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverCert.cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}}}
+	urlBase := runTestServer(t, httpServer, client, "https")
+
+	tls12Client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs, MaxVersion: tls.VersionTLS12}}}
+	_, err := tls12Client.Get(fmt.Sprintf("%s/config", urlBase))
+	assert.Error(err)
+}
+
+func TestTLSInvalidConfig(t *testing.T) {
+	assert := assert.New(t)
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["TLS_CERT_FILE"] = filepath.Join(t.TempDir(), "missing.crt")
+	configMap["TLS_KEY_FILE"] = filepath.Join(t.TempDir(), "missing.key")
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	assert.Error(httpServer.Run(context.Background()))
+}
+
+func TestTLSCertificateReload(t *testing.T) {
+	assert := assert.New(t)
+	ca := newTestCert(t, "ca", nil /*parent*/, nil /*template*/)
+	certDir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, certDir, newTestServerCert(t, "server-1", ca))
+
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["TLS_CERT_FILE"] = certFile
+	configMap["TLS_KEY_FILE"] = keyFile
+	configMap["TLS_RELOAD_INTERVAL"] = "1ms"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+	// Disable keep-alives so that every request performs a new TLS handshake
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}, DisableKeepAlives: true}}
+	urlBase := runTestServer(t, httpServer, client, "https")
+
+	peerCommonName := func() string {
+		resp, err := client.Get(fmt.Sprintf("%s/config", urlBase))
+		if err != nil {
+			return ""
+		}
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	assert.Equal("server-1", peerCommonName())
+
+	// Rotate the certificate on disk
+	writeTestCert(t, certDir, newTestServerCert(t, "server-2", ca))
+	assert.Eventually(func() bool {
+		return peerCommonName() == "server-2"
+	}, time.Second /*waitFor*/, serverStartTickMs*time.Millisecond /*tick*/)
+}
+
+func TestMutualTLS(t *testing.T) {
+	assert := assert.New(t)
+	ca := newTestCert(t, "ca", nil /*parent*/, nil /*template*/)
+	certDir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, certDir, newTestServerCert(t, "server", ca))
+	clientCAFile := filepath.Join(certDir, "ca.crt")
+	assert.NoError(ioutil.WriteFile(clientCAFile, ca.certPEM, 0600))
+
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["TLS_CERT_FILE"] = certFile
+	configMap["TLS_KEY_FILE"] = keyFile
+	configMap["TLS_CLIENT_AUTH"] = "verify"
+	configMap["TLS_CLIENT_CA_FILE"] = clientCAFile
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+	clientCert := newTestCert(t, "client", ca, &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	clientKeyPair, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+	if !assert.NoError(err) {
+		return
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs, Certificates: []tls.Certificate{clientKeyPair}}}}
+	urlBase := runTestServer(t, httpServer, client, "https")
+
+	// A client without a certificate is rejected
+	anonymousClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}}}
+	_, err = anonymousClient.Get(fmt.Sprintf("%s/config", urlBase))
+	assert.Error(err)
+
+	// A client with a certificate signed by an untrusted CA is rejected
+	untrustedCert := newTestCert(t, "untrusted", newTestCert(t, "untrusted-ca", nil /*parent*/, nil /*template*/),
+		&x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	untrustedKeyPair, err := tls.X509KeyPair(untrustedCert.certPEM, untrustedCert.keyPEM)
+	if assert.NoError(err) {
+		untrustedClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs, Certificates: []tls.Certificate{untrustedKeyPair}}}}
+		_, err = untrustedClient.Get(fmt.Sprintf("%s/config", urlBase))
+		assert.Error(err)
+	}
+
+	resp, err := client.Get(fmt.Sprintf("%s/config", urlBase))
+	if assert.NoError(err) {
+		defer resp.Body.Close()
+		assert.Equal(200, resp.StatusCode)
+	}
+}
+
+func TestMutualTLSMissingClientCA(t *testing.T) {
+	assert := assert.New(t)
+	certFile, keyFile := writeTestCert(t, t.TempDir(), newTestServerCert(t, "server", nil /*parent*/))
+
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["TLS_CERT_FILE"] = certFile
+	configMap["TLS_KEY_FILE"] = keyFile
+	configMap["TLS_CLIENT_AUTH"] = "verify"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	assert.Error(httpServer.Run(context.Background()))
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Environment variables used to hand off file descriptors from a parent process to
+// the child process it starts during a binary upgrade. These are set by the parent
+// process and are not meant to be configured by hand.
+const (
+	// The file descriptor of the inherited listening socket
+	upgradeListenerFDEnv = "HTTP_SERVER_UPGRADE_LISTENER_FD"
+	// The file descriptor of the inherited admin listening socket, if any
+	upgradeAdminListenerFDEnv = "HTTP_SERVER_UPGRADE_ADMIN_LISTENER_FD"
+	// The file descriptor of the inherited HTTP/3 UDP socket, if any
+	upgradeH3FDEnv = "HTTP_SERVER_UPGRADE_H3_FD"
+	// The file descriptor of the pipe used to notify the parent process that the child is ready
+	upgradeReadyFDEnv = "HTTP_SERVER_UPGRADE_READY_FD"
+)
+
+// The file descriptor assigned to the first entry in exec.Cmd.ExtraFiles
+const extraFilesStart = 3
+
+// Upgrade ...
+// Performs a zero-downtime binary upgrade: a new copy of the current executable is
+// started with the listening socket(s) handed off to it. This call blocks until the
+// child process reports that it is ready (in which case the caller should shut this
+// server down) or until the configured upgrade timeout expires (in which case the
+// child process is killed and this server continues to serve requests).
+func (s *HTTPServer) Upgrade() error {
+	if !atomic.CompareAndSwapInt32(&s.upgrading, 0, 1) {
+		return errors.New("HTTPServer upgrade already in progress")
+	}
+	defer atomic.StoreInt32(&s.upgrading, 0)
+
+	listener := s.activeListener()
+	if listener == nil {
+		return errors.New("HTTPServer upgrade failure: no active listener")
+	}
+	mainListenerFile, err := listenerFile(listener)
+	if err != nil {
+		return fmt.Errorf("HTTPServer upgrade failure: %w", err)
+	}
+	defer mainListenerFile.Close()
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("HTTPServer upgrade failure: unable to create ready pipe: %w", err)
+	}
+	defer readyReader.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		readyWriter.Close() // nolint:errcheck
+		return fmt.Errorf("HTTPServer upgrade failure: unable to find executable: %w", err)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+	// Hand off a file to the child process, letting it know the file descriptor via the given environment variable
+	handOff := func(env string, file *os.File) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", env, extraFilesStart+len(cmd.ExtraFiles)))
+		cmd.ExtraFiles = append(cmd.ExtraFiles, file)
+	}
+	handOff(upgradeListenerFDEnv, mainListenerFile)
+	handOff(upgradeReadyFDEnv, readyWriter)
+
+	if adminListener := s.activeAdminListener(); adminListener != nil {
+		adminListenerFile, err := listenerFile(adminListener)
+		if err != nil {
+			readyWriter.Close() // nolint:errcheck
+			return fmt.Errorf("HTTPServer upgrade failure: unable to hand off admin socket: %w", err)
+		}
+		defer adminListenerFile.Close()
+		handOff(upgradeAdminListenerFDEnv, adminListenerFile)
+	}
+	if s.h3Conn != nil {
+		h3File, err := s.h3Conn.(*net.UDPConn).File()
+		if err != nil {
+			readyWriter.Close() // nolint:errcheck
+			return fmt.Errorf("HTTPServer upgrade failure: unable to hand off HTTP/3 socket: %w", err)
+		}
+		defer h3File.Close()
+		handOff(upgradeH3FDEnv, h3File)
+	}
+
+	log.Info().Str("executable", executable).Msg("Starting HTTPServer upgrade")
+	startErr := cmd.Start()
+	// The child process holds its own copy of the write end of the pipe
+	readyWriter.Close() // nolint:errcheck
+	if startErr != nil {
+		return fmt.Errorf("HTTPServer upgrade failure: unable to start child process: %w", startErr)
+	}
+
+	// The child process writes to the pipe once it is serving requests. If it exits
+	// before then, the read returns EOF.
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := readyReader.Read(buf)
+		ready <- err
+	}()
+
+	select {
+	case err := <-ready:
+		if err == nil {
+			log.Info().Int("pid", cmd.Process.Pid).Msg("HTTPServer upgrade child process ready")
+			// The child process now owns the socket, so do not remove it when this process shuts down
+			if unixListener, ok := listener.(*net.UnixListener); ok {
+				unixListener.SetUnlinkOnClose(false)
+			}
+			// Do not wait on the child process, it will be re-parented once this process exits
+			cmd.Process.Release() // nolint:errcheck
+			return nil
+		}
+		cmd.Wait() // nolint:errcheck
+		return fmt.Errorf("HTTPServer upgrade failure: child process exited before becoming ready: %w", err)
+	case <-time.After(s.config.UpgradeTimeout):
+		cmd.Process.Kill() // nolint:errcheck
+		cmd.Wait()         // nolint:errcheck
+		return fmt.Errorf("HTTPServer upgrade failure: child process not ready after %s", s.config.UpgradeTimeout)
+	}
+}
+
+// ========== Private Helpers ==========
+
+// Returns a duplicate file descriptor for the given listener which can be handed off to a child process
+func listenerFile(listener net.Listener) (*os.File, error) {
+	switch l := listener.(type) {
+	case *net.TCPListener:
+		return l.File()
+	case *net.UnixListener:
+		return l.File()
+	default:
+		return nil, fmt.Errorf("unsupported listener type (%T)", listener)
+	}
+}
+
+// Returns the listener inherited from a parent process during a binary upgrade, if any
+func inheritedListener(env string) (net.Listener, error) {
+	file := inheritedFile(env)
+	if file == nil {
+		return nil, nil
+	}
+	defer file.Close()
+
+	// Note that the listener holds a duplicate of the file descriptor
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("error while listening on inherited socket: %w", err)
+	}
+	log.Info().Msgf("Using listener inherited from parent process on %s:%s", listener.Addr().Network(), listener.Addr())
+	return listener, nil
+}
+
+// Returns the HTTP/3 UDP socket inherited from a parent process during a binary upgrade, if any
+func inheritedPacketConn() (net.PacketConn, error) {
+	file := inheritedFile(upgradeH3FDEnv)
+	if file == nil {
+		return nil, nil
+	}
+	defer file.Close()
+
+	conn, err := net.FilePacketConn(file)
+	if err != nil {
+		return nil, fmt.Errorf("error while using inherited HTTP/3 socket: %w", err)
+	}
+	return conn, nil
+}
+
+// Lets the parent process know that this process is ready, if this process was started by a binary upgrade
+func (s *HTTPServer) notifyUpgradeReady() {
+	file := inheritedFile(upgradeReadyFDEnv)
+	if file == nil {
+		return
+	}
+	defer file.Close()
+
+	log.Info().Int("ppid", os.Getppid()).Msg("Notifying parent process that HTTPServer is ready")
+	if _, err := file.Write([]byte{1}); err != nil {
+		log.Error().Err(err).Msg("Error while notifying parent process of HTTPServer readiness")
+	}
+}
+
+// Returns the inherited file whose descriptor is set in the given environment variable, if any.
+// The variable is unset so that it is not inherited by any future child processes.
+func inheritedFile(env string) *os.File {
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return nil
+	}
+	os.Unsetenv(env) // nolint:errcheck
+
+	fd, err := strconv.Atoi(value)
+	if err != nil || fd < extraFilesStart {
+		log.Warn().Str("env", env).Str("value", value).Msg("Ignoring invalid inherited file descriptor")
+		return nil
+	}
+	// Ensure that the file descriptor is not leaked to any future child processes
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), env)
+}
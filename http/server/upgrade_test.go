@@ -0,0 +1,155 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a raw duplicate of the given file's descriptor, as would be inherited by a child process
+func dupFD(t *testing.T, file *os.File) int {
+	fd, err := syscall.Dup(int(file.Fd()))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	return fd
+}
+
+func TestUpgradeInheritedListener(t *testing.T) {
+	assert := assert.New(t)
+	// Simulate the sockets handed off by a parent process
+	parentListener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer parentListener.Close()
+	parentListenerFile, err := parentListener.(*net.TCPListener).File()
+	require.NoError(t, err)
+	readyReader, readyWriter, err := os.Pipe()
+	require.NoError(t, err)
+	defer readyReader.Close()
+
+	t.Setenv("HTTP_SERVER_UPGRADE_LISTENER_FD", fmt.Sprint(dupFD(t, parentListenerFile)))
+	t.Setenv("HTTP_SERVER_UPGRADE_READY_FD", fmt.Sprint(dupFD(t, readyWriter)))
+
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		assert.NoError(<-runErr)
+	}()
+
+	// The parent process is notified once the server is ready
+	readyErr := make(chan error, 1)
+	go func() {
+		_, err := readyReader.Read(make([]byte, 1))
+		readyErr <- err
+	}()
+	select {
+	case err := <-readyErr:
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		assert.Fail("HTTPServer did not notify parent process of readiness")
+	}
+
+	// The server serves requests on the inherited socket
+	assert.Equal(parentListener.Addr().(*net.TCPAddr).Port, httpServer.ActivePort())
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/ready", httpServer.ActivePort()))
+	if assert.NoError(err) {
+		defer resp.Body.Close()
+		assert.Equal(200, resp.StatusCode)
+	}
+}
+
+func TestUpgradeNotRunning(t *testing.T) {
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	assert.Error(t, httpServer.Upgrade())
+}
+
+// The environment variable which runs TestUpgradeChildProcess as the child process of an
+// upgrade, along with how the child behaves (ready|hang)
+const upgradeChildEnv = "UPGRADE_TEST_CHILD"
+
+// Runs the HTTPServer on a Unix domain socket and starts an upgrade, which re-runs this test
+// binary as the child process in the given mode
+func runUpgrade(t *testing.T, childMode string, upgradeTimeout string) (string, error) {
+	socketPath := filepath.Join(t.TempDir(), "app.sock")
+	configMap := make(map[string]string)
+	configMap["LOG_LEVEL"] = "trace"
+	configMap["LISTEN"] = fmt.Sprintf("unix://%s", socketPath)
+	configMap["UPGRADE_TIMEOUT"] = upgradeTimeout
+	httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return httpServer.ActiveAddr() != nil
+	}, serverStartTimeoutMs*time.Millisecond /*waitFor*/, serverStartTickMs*time.Millisecond /*tick*/)
+
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestUpgradeChildProcess$"}
+	defer func() { os.Args = args }()
+	t.Setenv(upgradeChildEnv, childMode)
+
+	upgradeErr := httpServer.Upgrade()
+	cancel()
+	assert.NoError(t, <-runErr)
+	return socketPath, upgradeErr
+}
+
+func TestUpgrade(t *testing.T) {
+	socketPath, err := runUpgrade(t, "ready", "10s")
+	assert.NoError(t, err)
+	// The child process owns the socket, so this server leaves it in place on shutdown
+	_, err = os.Stat(socketPath)
+	assert.NoError(t, err)
+}
+
+func TestUpgradeTimeout(t *testing.T) {
+	socketPath, err := runUpgrade(t, "hang", "500ms")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "child process not ready after 500ms")
+	}
+	// This server still owns the socket, so it is removed on shutdown
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// Not a test in itself, but rather the child process started by TestUpgrade and TestUpgradeTimeout
+func TestUpgradeChildProcess(t *testing.T) {
+	switch os.Getenv(upgradeChildEnv) {
+	case "ready":
+		configMap := make(map[string]string)
+		configMap["LOG_LEVEL"] = "trace"
+		httpServer, _ := server.InitializeHTTPServer(envconfig.MapLookuper(configMap))
+		// Serve on the inherited socket (which notifies the parent process) for a moment before exiting
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, httpServer.Run(ctx))
+	case "hang":
+		// Never notify the parent process, which kills this process once the upgrade times out
+		select {}
+	default:
+		t.Skip("Only run as the child process of an upgrade")
+	}
+}
@@ -1,3 +1,4 @@
+//go:build wireinject
 // +build wireinject
 
 package server
@@ -5,8 +6,10 @@ package server
 import (
 	"github.com/google/wire"
 	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server/auth"
 	"github.com/spals/starter-kit/http/server/config"
 	"github.com/spals/starter-kit/http/server/handler"
+	"github.com/spals/starter-kit/http/server/middleware"
 )
 
 // InitializeHTTPServer ...
@@ -14,9 +17,15 @@ func InitializeHTTPServer(l envconfig.Lookuper) (*HTTPServer, error) {
 	wire.Build(
 		// Configuration
 		config.NewHTTPServerConfig,
+		// Middleware
+		middleware.NewPanicCounter,
+		middleware.NewMetrics,
+		middleware.NewTracing,
+		// Authentication
+		auth.NewJWTVerifier,
+		auth.NewAPIKeyVerifier,
 		// Handlers
-		handler.NewHealthCheckHandler,
-		handler.NewHTTPServerConfigHandler,
+		handler.ProviderSet,
 		// Server
 		NewHTTPServer,
 	)
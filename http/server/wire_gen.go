@@ -1,23 +1,47 @@
 // Code generated by Wire. DO NOT EDIT.
 
-//go:generate wire
-//+build !wireinject
+//go:generate go run github.com/google/wire/cmd/wire
+//go:build !wireinject
+// +build !wireinject
 
 package server
 
 import (
 	"github.com/sethvargo/go-envconfig"
+	"github.com/spals/starter-kit/http/server/auth"
 	"github.com/spals/starter-kit/http/server/config"
 	"github.com/spals/starter-kit/http/server/handler"
+	"github.com/spals/starter-kit/http/server/middleware"
 )
 
 // Injectors from wire.go:
 
 // InitializeHTTPServer ...
 func InitializeHTTPServer(l envconfig.Lookuper) (*HTTPServer, error) {
-	httpServerConfig := config.NewHTTPServerConfig(l)
-	healthcheckHandler := handler.NewHealthCheckHandler(httpServerConfig)
+	httpServerConfig, err := config.NewHTTPServerConfig(l)
+	if err != nil {
+		return nil, err
+	}
+	panicCounter := middleware.NewPanicCounter()
+	healthcheckHandler := handler.NewHealthCheckHandler(httpServerConfig, panicCounter)
+	metrics := middleware.NewMetrics()
+	tracing, err := middleware.NewTracing(httpServerConfig)
+	if err != nil {
+		return nil, err
+	}
+	jwtVerifier, err := auth.NewJWTVerifier(httpServerConfig)
+	if err != nil {
+		return nil, err
+	}
+	apiKeyVerifier, err := auth.NewAPIKeyVerifier(httpServerConfig)
+	if err != nil {
+		return nil, err
+	}
+	healthCheckRouteProvider := handler.NewHealthCheckRouteProvider(healthcheckHandler)
 	httpServerConfigHandler := handler.NewHTTPServerConfigHandler(httpServerConfig)
-	httpServer := NewHTTPServer(httpServerConfig, healthcheckHandler, httpServerConfigHandler)
+	metricsRouteProvider := handler.NewMetricsRouteProvider(metrics)
+	debugRouteProvider := handler.NewDebugRouteProvider(httpServerConfig)
+	v := handler.NewRouteProviders(healthCheckRouteProvider, httpServerConfigHandler, metricsRouteProvider, debugRouteProvider)
+	httpServer := NewHTTPServer(httpServerConfig, healthcheckHandler, panicCounter, metrics, tracing, jwtVerifier, apiKeyVerifier, v)
 	return httpServer, nil
 }